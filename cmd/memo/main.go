@@ -1,13 +1,19 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"reflect"
 	"runtime"
 	"time"
 
+	"github.com/allegro/bigcache/v3"
+	"github.com/reksie/tieredcache/pkg/interfaces"
 	"github.com/reksie/tieredcache/pkg/keys"
+	"github.com/reksie/tieredcache/pkg/memo"
+	"github.com/reksie/tieredcache/pkg/stores"
+	"github.com/reksie/tieredcache/pkg/tieredcache"
 )
 
 func MakeTimedFunction[T any](f T) T {
@@ -102,4 +108,29 @@ func main() {
 
 	timedTwoParams(10, "World")
 
+	memoExample()
+}
+
+// memoExample memoizes a slow function in a tiered cache, the second call is served from memory
+func memoExample() {
+	bigcacheInstance, err := bigcache.New(context.Background(), bigcache.DefaultConfig(10*time.Minute))
+	if err != nil {
+		fmt.Printf("Error creating BigCache: %v\n", err)
+		return
+	}
+	cache := tieredcache.NewTieredCache(5*time.Second, []interfaces.CacheStore{stores.CreateMemoryStore("memory", bigcacheInstance)})
+	defer cache.Close()
+
+	memoSlow := memo.Memoize2(memo.Options{TieredCache: cache, Name: "slow", TTL: time.Minute}, func(ctx context.Context, x int, y string) (string, error) {
+		return MakeTimedFunction(func(x int, y string) (string, error) {
+			time.Sleep(100 * time.Millisecond)
+			return fmt.Sprintf("%s %d", y, x), nil
+		})(x, y)
+	})
+
+	for i := 0; i < 2; i++ {
+		start := time.Now()
+		value, err := memoSlow(context.Background(), 10, "Memo")
+		fmt.Printf("Memoized call returned %v, %v in %v\n", value, err, time.Since(start))
+	}
 }
@@ -1,43 +1,199 @@
 package internal
 
 import (
-	"encoding/json"
+	"container/list"
 	"fmt"
 	"reflect"
+	"sync"
+	"time"
+
+	"github.com/reksie/tieredcache/pkg/keys"
 )
 
-// Memoize takes a function with any number of arguments and returns a naive memoized version of that function
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// MemoizeOptions configures a locally memoized function.
+type MemoizeOptions struct {
+	// MaxEntries bounds the number of cached results, evicting the least recently used. 0 means unbounded.
+	MaxEntries int
+	// TTL expires cached results after the given duration. 0 means results never expire.
+	TTL time.Duration
+	// SkipErrors does not cache results when the function's last return value is a non-nil error.
+	SkipErrors bool
+}
+
+// MemoStats reports how a memoized function has been used.
+type MemoStats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	Entries   int
+}
+
+// Memo is a handle on the cache of a memoized function.
+type Memo struct {
+	options MemoizeOptions
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+	stats   MemoStats
+}
+
+type memoEntry struct {
+	key       string
+	results   []reflect.Value
+	expiresAt time.Time
+}
+
+// Memoize takes a function with any number of arguments and returns a memoized version of that function.
+// Results are kept forever, use MemoizeLocal to bound the cache.
 func Memoize(f interface{}) interface{} {
+	memoized, _ := MemoizeLocal(f, MemoizeOptions{})
+	return memoized
+}
+
+// MemoizeLocal memoizes f in process and returns the memoized function along with a handle to its cache.
+// The memoized function is safe for concurrent use, though concurrent misses for the same arguments may each call f.
+func MemoizeLocal(f interface{}, opts MemoizeOptions) (interface{}, *Memo) {
 	ft := reflect.TypeOf(f)
-	if ft.Kind() != reflect.Func {
+	if ft == nil || ft.Kind() != reflect.Func {
 		panic("Memoize: argument must be a function")
 	}
 
-	cache := make(map[string][]reflect.Value)
+	m := &Memo{
+		options: opts,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
 
-	return reflect.MakeFunc(ft, func(args []reflect.Value) []reflect.Value {
+	returnsError := ft.NumOut() > 0 && ft.Out(ft.NumOut()-1) == errorType
+	fv := reflect.ValueOf(f)
+
+	memoized := reflect.MakeFunc(ft, func(args []reflect.Value) []reflect.Value {
 		key, err := hashArgs(args)
 		if err != nil {
 			panic(fmt.Sprintf("Memoize: failed to hash arguments: %v", err))
 		}
 
-		if cached, found := cache[key]; found {
+		if cached, found := m.get(key); found {
 			return cached
 		}
 
-		results := reflect.ValueOf(f).Call(args)
-		cache[key] = results
+		var results []reflect.Value
+		if ft.IsVariadic() {
+			results = fv.CallSlice(args)
+		} else {
+			results = fv.Call(args)
+		}
+
+		if opts.SkipErrors && returnsError && !results[len(results)-1].IsNil() {
+			return results
+		}
+
+		m.set(key, results)
 		return results
 	}).Interface()
+
+	return memoized, m
+}
+
+// Forget removes the cached result for the given arguments.
+func (m *Memo) Forget(args ...any) error {
+	values := make([]reflect.Value, len(args))
+	for i, arg := range args {
+		values[i] = reflect.ValueOf(arg)
+	}
+	key, err := hashArgs(values)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if element, found := m.entries[key]; found {
+		m.removeElement(element)
+	}
+	return nil
+}
+
+// Reset removes all cached results.
+func (m *Memo) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries = make(map[string]*list.Element)
+	m.lru.Init()
+}
+
+// Stats returns a snapshot of the cache statistics.
+func (m *Memo) Stats() MemoStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stats := m.stats
+	stats.Entries = m.lru.Len()
+	return stats
+}
+
+func (m *Memo) get(key string) ([]reflect.Value, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	element, found := m.entries[key]
+	if !found {
+		m.stats.Misses++
+		return nil, false
+	}
+
+	entry := element.Value.(*memoEntry)
+	if !entry.expiresAt.IsZero() && time.Now().After(entry.expiresAt) {
+		m.removeElement(element)
+		m.stats.Misses++
+		return nil, false
+	}
+
+	m.lru.MoveToFront(element)
+	m.stats.Hits++
+	return entry.results, true
+}
+
+func (m *Memo) set(key string, results []reflect.Value) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry := &memoEntry{key: key, results: results}
+	if m.options.TTL > 0 {
+		entry.expiresAt = time.Now().Add(m.options.TTL)
+	}
+
+	if element, found := m.entries[key]; found {
+		element.Value = entry
+		m.lru.MoveToFront(element)
+		return
+	}
+
+	m.entries[key] = m.lru.PushFront(entry)
+
+	if m.options.MaxEntries > 0 && m.lru.Len() > m.options.MaxEntries {
+		m.removeElement(m.lru.Back())
+		m.stats.Evictions++
+	}
+}
+
+func (m *Memo) removeElement(element *list.Element) {
+	m.lru.Remove(element)
+	delete(m.entries, element.Value.(*memoEntry).key)
 }
 
 // hashArgs creates a string hash of the given arguments
 func hashArgs(args []reflect.Value) (string, error) {
 	var values []interface{}
 	for _, arg := range args {
+		if !arg.IsValid() {
+			values = append(values, nil)
+			continue
+		}
 		values = append(values, arg.Interface())
 	}
-	bytes, err := json.Marshal(values)
+	bytes, err := keys.CanonicalEncode(values)
 	if err != nil {
 		return "", err
 	}
@@ -0,0 +1,131 @@
+package internal
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoize(t *testing.T) {
+	var calls int
+	double := Memoize(func(x int) int {
+		calls++
+		return x * 2
+	}).(func(int) int)
+
+	assert.Equal(t, 4, double(2))
+	assert.Equal(t, 4, double(2))
+	assert.Equal(t, 1, calls)
+}
+
+func TestMemoizeLocalConcurrent(t *testing.T) {
+	var calls int32
+	fn, memo := MemoizeLocal(func(x int) int {
+		atomic.AddInt32(&calls, 1)
+		return x * 2
+	}, MemoizeOptions{MaxEntries: 10})
+	double := fn.(func(int) int)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			assert.Equal(t, (i%20)*2, double(i%20))
+		}(i)
+	}
+	wg.Wait()
+
+	assert.LessOrEqual(t, memo.Stats().Entries, 10)
+}
+
+func TestMemoizeLocalEviction(t *testing.T) {
+	var calls int
+	fn, memo := MemoizeLocal(func(x int) int {
+		calls++
+		return x
+	}, MemoizeOptions{MaxEntries: 2})
+	identity := fn.(func(int) int)
+
+	identity(1)
+	identity(2)
+	identity(1) // 1 is now the most recently used
+	identity(3) // evicts 2
+
+	assert.Equal(t, 3, calls)
+	identity(1)
+	assert.Equal(t, 3, calls)
+	identity(2)
+	assert.Equal(t, 4, calls)
+
+	stats := memo.Stats()
+	assert.Equal(t, uint64(2), stats.Evictions)
+	assert.Equal(t, 2, stats.Entries)
+}
+
+func TestMemoizeLocalTTL(t *testing.T) {
+	var calls int
+	fn, _ := MemoizeLocal(func(x int) int {
+		calls++
+		return x
+	}, MemoizeOptions{TTL: 20 * time.Millisecond})
+	identity := fn.(func(int) int)
+
+	identity(1)
+	identity(1)
+	assert.Equal(t, 1, calls)
+
+	time.Sleep(30 * time.Millisecond)
+
+	identity(1)
+	assert.Equal(t, 2, calls)
+}
+
+func TestMemoizeLocalSkipErrors(t *testing.T) {
+	var calls int
+	fn, memo := MemoizeLocal(func(x int) (int, error) {
+		calls++
+		if calls == 1 {
+			return 0, errors.New("boom")
+		}
+		return x, nil
+	}, MemoizeOptions{SkipErrors: true})
+	flaky := fn.(func(int) (int, error))
+
+	_, err := flaky(1)
+	assert.Error(t, err)
+
+	value, err := flaky(1)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, value)
+
+	value, err = flaky(1)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, value)
+	assert.Equal(t, 2, calls)
+
+	stats := memo.Stats()
+	assert.Equal(t, uint64(1), stats.Hits)
+	assert.Equal(t, uint64(2), stats.Misses)
+}
+
+func TestMemoizeLocalForget(t *testing.T) {
+	var calls int
+	fn, memo := MemoizeLocal(func(name string, tags map[string]bool) int {
+		calls++
+		return len(tags)
+	}, MemoizeOptions{})
+	count := fn.(func(string, map[string]bool) int)
+
+	count("a", map[string]bool{"x": true, "y": true})
+	count("a", map[string]bool{"y": true, "x": true})
+	assert.Equal(t, 1, calls)
+
+	assert.NoError(t, memo.Forget("a", map[string]bool{"x": true, "y": true}))
+	count("a", map[string]bool{"x": true, "y": true})
+	assert.Equal(t, 2, calls)
+}
@@ -0,0 +1,16 @@
+package interfaces
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrNotFound is returned or wrapped by stores when a key is missing or expired.
+var ErrNotFound = errors.New("key not found in cache")
+
+// ErrExpired is returned by stores that notice a key has expired, it wraps ErrNotFound so it is handled as a miss.
+var ErrExpired = fmt.Errorf("%w: key expired", ErrNotFound)
+
+// ErrCorrupt is wrapped by stores when an entry exists but cannot be decoded, such as a truncated value,
+// a checksum mismatch or data written in another format. TieredCache treats it as a miss and deletes the entry.
+var ErrCorrupt = errors.New("corrupt cache entry")
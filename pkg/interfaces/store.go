@@ -25,3 +25,46 @@ type CacheStore interface {
 	// Close releases any resources or connections when the cache is no longer in use (optional).
 	Close() error
 }
+
+// BatchStore is implemented by stores that can read or write several keys in a single round trip.
+type BatchStore interface {
+	// GetMany retrieves the values of the keys that are found, missing keys are left out of the result.
+	GetMany(ctx context.Context, keys []string) (map[string]any, error)
+
+	// SetMany stores all values with the same TTL.
+	SetMany(ctx context.Context, values map[string]any, ttl time.Duration) error
+}
+
+// TTLStore is implemented by stores that know how long an entry has left to live.
+// TieredCache uses it to give entries promoted to faster tiers the same expiry as the tier they were found in.
+type TTLStore interface {
+	// GetWithTTL retrieves a value and its remaining TTL, which is 0 when the entry does not expire.
+	GetWithTTL(ctx context.Context, key string) (any, time.Duration, error)
+}
+
+// EvictionNotifier is implemented by bounded stores that remove entries to make room for new ones.
+type EvictionNotifier interface {
+	// OnEvict registers fn to be called with the key of every entry evicted to make room,
+	// after the store is unlocked. Entries that expire or are deleted are not reported.
+	OnEvict(fn func(key string))
+}
+
+// StoreStats describes what a store holds. Stores leave what they do not track as 0.
+type StoreStats struct {
+	// Entries is the number of entries, possibly including expired ones that were not removed yet.
+	Entries int64
+	// Bytes is the memory or storage used, which may be an estimate or include the store's own overhead.
+	Bytes int64
+	// Hits and Misses are reads as counted by the store itself, including those of other clients.
+	Hits   uint64
+	Misses uint64
+	// Evictions is the number of entries removed to make room for new ones.
+	Evictions uint64
+}
+
+// StatsStore is implemented by stores that can report statistics about themselves.
+type StatsStore interface {
+	// Stats may query a remote server, which is why it takes a context. Stores wrapping another one
+	// return errors.ErrUnsupported when the wrapped store cannot report statistics.
+	Stats(ctx context.Context) (StoreStats, error)
+}
@@ -0,0 +1,360 @@
+package keys
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// UnexportedFieldPolicy decides what the canonical encoder does with unexported struct fields.
+type UnexportedFieldPolicy int
+
+const (
+	// IgnoreUnexported skips unexported fields, like encoding/json does.
+	IgnoreUnexported UnexportedFieldPolicy = iota
+	// IncludeUnexported encodes unexported fields using their Go field name.
+	IncludeUnexported
+	// ErrorOnUnexported fails the encoding when a struct has unexported fields,
+	// so keys never silently ignore state.
+	ErrorOnUnexported
+)
+
+// CanonicalOptions configures the canonical encoder.
+type CanonicalOptions struct {
+	Unexported UnexportedFieldPolicy
+	// NilAsEmpty encodes nil slices and maps the same as empty ones.
+	// By default nil encodes as null and empty as [] or {}, as in encoding/json.
+	NilAsEmpty bool
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+var textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+// CanonicalEncode encodes v into a stable, JSON-like representation suitable for hashing.
+//
+// Unlike a json.Marshal round-trip it keeps full int64/uint64 precision, orders maps of any key type,
+// orders struct fields by name, writes time.Time as UTC RFC3339Nano, encodes NaN and infinities and
+// detects pointer cycles. The output is not guaranteed to be valid JSON.
+func CanonicalEncode(v any) ([]byte, error) {
+	return CanonicalOptions{}.Encode(v)
+}
+
+// Encode encodes v using the options, see CanonicalEncode.
+func (o CanonicalOptions) Encode(v any) ([]byte, error) {
+	e := &canonicalEncoder{options: o, visited: make(map[visit]bool)}
+	if err := e.encode(addressable(reflect.ValueOf(v))); err != nil {
+		return nil, err
+	}
+	return e.buf.Bytes(), nil
+}
+
+// HashKeyCanonical is a HashFunc returning the readable canonical encoding of the data.
+func HashKeyCanonical(data ...any) (string, error) {
+	encoded, err := CanonicalEncode(data)
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
+
+// HashKeyCanonicalMD5 is a HashFunc returning the MD5 of the canonical encoding of the data.
+func HashKeyCanonicalMD5(data ...any) (string, error) {
+	encoded, err := CanonicalEncode(data)
+	if err != nil {
+		return "", err
+	}
+	sum := md5.Sum(encoded)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+type canonicalEncoder struct {
+	options CanonicalOptions
+	buf     bytes.Buffer
+	visited map[visit]bool
+}
+
+// visit identifies a pointer, map or slice being encoded. Slices sharing an array only overlap
+// when they have the same length too, and a struct shares its address with its first field.
+type visit struct {
+	ptr uintptr
+	len int
+	typ reflect.Type
+}
+
+// enter marks a pointer, map or slice as being encoded, failing when it already is because the value
+// contains itself. The returned function unmarks it.
+func (e *canonicalEncoder) enter(v reflect.Value) (func(), error) {
+	key := visit{ptr: v.Pointer(), typ: v.Type()}
+	if v.Kind() == reflect.Slice {
+		key.len = v.Len()
+	}
+	if e.visited[key] {
+		return nil, fmt.Errorf("keys: cycle detected at %s", v.Type())
+	}
+	e.visited[key] = true
+	return func() { delete(e.visited, key) }, nil
+}
+
+func (e *canonicalEncoder) encode(v reflect.Value) error {
+	if !v.IsValid() {
+		e.buf.WriteString("null")
+		return nil
+	}
+
+	if v.Type() == timeType {
+		t, err := e.timeValue(v)
+		if err != nil {
+			return err
+		}
+		e.buf.WriteString(strconv.Quote(t.UTC().Format(time.RFC3339Nano)))
+		return nil
+	}
+
+	if marshaler, ok := textMarshaler(v); ok {
+		text, err := marshaler.MarshalText()
+		if err != nil {
+			return err
+		}
+		e.buf.WriteString(strconv.Quote(string(text)))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		e.buf.WriteString(strconv.FormatBool(v.Bool()))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		e.buf.WriteString(strconv.FormatInt(v.Int(), 10))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		e.buf.WriteString(strconv.FormatUint(v.Uint(), 10))
+	case reflect.Float32, reflect.Float64:
+		e.encodeFloat(v.Float(), v.Type().Bits())
+	case reflect.Complex64, reflect.Complex128:
+		c := v.Complex()
+		e.buf.WriteByte('[')
+		e.encodeFloat(real(c), 64)
+		e.buf.WriteByte(',')
+		e.encodeFloat(imag(c), 64)
+		e.buf.WriteByte(']')
+	case reflect.String:
+		e.buf.WriteString(strconv.Quote(v.String()))
+	case reflect.Interface:
+		return e.encode(addressable(v.Elem()))
+	case reflect.Pointer:
+		if v.IsNil() {
+			e.buf.WriteString("null")
+			return nil
+		}
+		leave, err := e.enter(v)
+		if err != nil {
+			return err
+		}
+		defer leave()
+		return e.encode(v.Elem())
+	case reflect.Slice:
+		if v.IsNil() && !e.options.NilAsEmpty {
+			e.buf.WriteString("null")
+			return nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			e.buf.WriteString(strconv.Quote(base64.StdEncoding.EncodeToString(v.Bytes())))
+			return nil
+		}
+		if v.Len() > 0 {
+			leave, err := e.enter(v)
+			if err != nil {
+				return err
+			}
+			defer leave()
+		}
+		return e.encodeList(v)
+	case reflect.Array:
+		return e.encodeList(v)
+	case reflect.Map:
+		if v.IsNil() && !e.options.NilAsEmpty {
+			e.buf.WriteString("null")
+			return nil
+		}
+		if !v.IsNil() {
+			leave, err := e.enter(v)
+			if err != nil {
+				return err
+			}
+			defer leave()
+		}
+		return e.encodeMap(v)
+	case reflect.Struct:
+		return e.encodeStruct(v)
+	default:
+		return fmt.Errorf("keys: unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// timeValue reads a time.Time, including from unexported fields where Interface is not allowed.
+func (e *canonicalEncoder) timeValue(v reflect.Value) (time.Time, error) {
+	if v.CanInterface() {
+		return v.Interface().(time.Time), nil
+	}
+	if v.CanAddr() {
+		return *(*time.Time)(v.Addr().UnsafePointer()), nil
+	}
+	return time.Time{}, fmt.Errorf("keys: cannot read unexported %s", v.Type())
+}
+
+// addressable copies v into an addressable value so nested unexported fields can still be read.
+func addressable(v reflect.Value) reflect.Value {
+	if !v.IsValid() || v.CanAddr() || !v.CanInterface() {
+		return v
+	}
+	copied := reflect.New(v.Type()).Elem()
+	copied.Set(v)
+	return copied
+}
+
+// textMarshaler returns v as an encoding.TextMarshaler if either it or its address implements it.
+func textMarshaler(v reflect.Value) (encoding.TextMarshaler, bool) {
+	if v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		return nil, false
+	}
+	if v.CanInterface() && v.Type().Implements(textMarshalerType) {
+		return v.Interface().(encoding.TextMarshaler), true
+	}
+	if v.CanAddr() && v.Addr().CanInterface() && v.Addr().Type().Implements(textMarshalerType) {
+		return v.Addr().Interface().(encoding.TextMarshaler), true
+	}
+	return nil, false
+}
+
+func (e *canonicalEncoder) encodeFloat(f float64, bits int) {
+	switch {
+	case math.IsNaN(f):
+		e.buf.WriteString("NaN")
+	case math.IsInf(f, 1):
+		e.buf.WriteString("+Inf")
+	case math.IsInf(f, -1):
+		e.buf.WriteString("-Inf")
+	case f == 0:
+		// Fold -0 into 0
+		e.buf.WriteString("0")
+	default:
+		e.buf.WriteString(strconv.FormatFloat(f, 'g', -1, bits))
+	}
+}
+
+func (e *canonicalEncoder) encodeList(v reflect.Value) error {
+	e.buf.WriteByte('[')
+	for i := 0; i < v.Len(); i++ {
+		if i > 0 {
+			e.buf.WriteByte(',')
+		}
+		if err := e.encode(v.Index(i)); err != nil {
+			return err
+		}
+	}
+	e.buf.WriteByte(']')
+	return nil
+}
+
+func (e *canonicalEncoder) encodeMap(v reflect.Value) error {
+	type entry struct {
+		key   string
+		value reflect.Value
+	}
+
+	entries := make([]entry, 0, v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		keyEncoder := &canonicalEncoder{options: e.options, visited: e.visited}
+		if err := keyEncoder.encode(iter.Key()); err != nil {
+			return err
+		}
+		entries = append(entries, entry{key: keyEncoder.buf.String(), value: addressable(iter.Value())})
+	}
+
+	// Sorting on the encoded key gives a stable order for any key type
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].key < entries[j].key
+	})
+
+	e.buf.WriteByte('{')
+	for i, en := range entries {
+		if i > 0 {
+			e.buf.WriteByte(',')
+		}
+		e.buf.WriteString(en.key)
+		e.buf.WriteByte(':')
+		if err := e.encode(en.value); err != nil {
+			return err
+		}
+	}
+	e.buf.WriteByte('}')
+	return nil
+}
+
+func (e *canonicalEncoder) encodeStruct(v reflect.Value) error {
+	type field struct {
+		name  string
+		value reflect.Value
+	}
+
+	t := v.Type()
+	fields := make([]field, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			switch e.options.Unexported {
+			case IgnoreUnexported:
+				continue
+			case ErrorOnUnexported:
+				return fmt.Errorf("keys: unexported field %s.%s", t, sf.Name)
+			}
+		}
+
+		name := sf.Name
+		omitEmpty := false
+		if tag, ok := sf.Tag.Lookup("json"); ok {
+			tagName, tagOptions, _ := strings.Cut(tag, ",")
+			if tagName == "-" && tagOptions == "" {
+				continue
+			}
+			if tagName != "" {
+				name = tagName
+			}
+			omitEmpty = strings.Contains(","+tagOptions+",", ",omitempty,")
+		}
+
+		value := v.Field(i)
+		if omitEmpty && value.IsZero() {
+			continue
+		}
+		fields = append(fields, field{name: name, value: value})
+	}
+
+	// Sorting by name means reordering struct fields does not change keys
+	sort.SliceStable(fields, func(i, j int) bool {
+		return fields[i].name < fields[j].name
+	})
+
+	e.buf.WriteByte('{')
+	for i, f := range fields {
+		if i > 0 {
+			e.buf.WriteByte(',')
+		}
+		e.buf.WriteString(strconv.Quote(f.name))
+		e.buf.WriteByte(':')
+		if err := e.encode(f.value); err != nil {
+			return err
+		}
+	}
+	e.buf.WriteByte('}')
+	return nil
+}
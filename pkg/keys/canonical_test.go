@@ -0,0 +1,175 @@
+package keys
+
+import (
+	"flag"
+	"math"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+type canonicalNested struct {
+	B int
+	A string
+}
+
+type canonicalExample struct {
+	Name     string           `json:"name"`
+	Skipped  string           `json:"-"`
+	Optional string           `json:"optional,omitempty"`
+	Meta     map[string]any   `json:"meta"`
+	Nest     canonicalNested  `json:"nest"`
+	Ptr      *canonicalNested `json:"ptr"`
+	Tags     []string         `json:"tags"`
+	ByID     map[int]string   `json:"by_id"`
+	Created  time.Time        `json:"created"`
+	hidden   string
+	Counters map[string]uint64 `json:"counters"`
+}
+
+type canonicalUnexported struct {
+	Public  string
+	private int
+	when    time.Time
+}
+
+type canonicalCycle struct {
+	Next *canonicalCycle
+}
+
+func TestCanonicalEncodeGolden(t *testing.T) {
+	created := time.Date(2024, 3, 1, 12, 30, 0, 123456789, time.FixedZone("CET", 3600))
+
+	tests := []struct {
+		name    string
+		value   any
+		options CanonicalOptions
+	}{
+		{name: "scalars", value: []any{true, -12, uint8(7), 1.5, float32(0.1), "quote\"d", nil}},
+		{name: "large_ints", value: []any{int64(math.MaxInt64), uint64(math.MaxUint64), int64(1<<53 + 1)}},
+		{name: "floats", value: []float64{math.NaN(), math.Inf(1), math.Inf(-1), math.Copysign(0, -1), 1e21}},
+		{name: "map_string_keys", value: map[string]int{"b": 2, "a": 1, "c": 3}},
+		{name: "map_int_keys", value: map[int]string{10: "ten", 2: "two", -1: "minus one"}},
+		{name: "map_mixed_keys", value: map[any]string{"1": "string", 1: "int", true: "bool"}},
+		{name: "nil_and_empty", value: []any{[]string(nil), []string{}, map[string]int(nil), map[string]int{}}},
+		{name: "nil_as_empty", value: []any{[]string(nil), []string{}, map[string]int(nil), map[string]int{}}, options: CanonicalOptions{NilAsEmpty: true}},
+		{name: "time", value: []any{created, &created, time.Time{}}},
+		{name: "text_marshaler", value: []any{big.NewInt(0).Lsh(big.NewInt(1), 100), *big.NewInt(42)}},
+		{name: "bytes", value: []byte("hello")},
+		{
+			name: "struct",
+			value: canonicalExample{
+				Name:    "test",
+				Skipped: "skipped",
+				Meta: map[string]any{
+					"keyB": "value2",
+					"keyA": map[string]any{"subKeyB": 2, "subKeyA": 1},
+				},
+				Nest:     canonicalNested{B: 1, A: "a"},
+				Ptr:      &canonicalNested{B: 2, A: "b"},
+				Tags:     []string{"tag2", "tag1"},
+				ByID:     map[int]string{2: "two", 1: "one"},
+				Created:  created,
+				hidden:   "hidden",
+				Counters: map[string]uint64{"big": math.MaxUint64},
+			},
+		},
+		{name: "unexported_ignored", value: canonicalUnexported{Public: "p", private: 1, when: created}},
+		{name: "unexported_included", value: canonicalUnexported{Public: "p", private: 1, when: created}, options: CanonicalOptions{Unexported: IncludeUnexported}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.options.Encode(tt.value)
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			golden := filepath.Join("testdata", "canonical", tt.name+".golden")
+			if *update {
+				if err := os.MkdirAll(filepath.Dir(golden), 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(golden, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			expected, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("failed to read golden file (run with -update to create it): %v", err)
+			}
+			if string(got) != string(expected) {
+				t.Errorf("expected %s, got %s", expected, got)
+			}
+		})
+	}
+}
+
+func TestCanonicalEncodeStable(t *testing.T) {
+	value := map[string]any{}
+	for i := 0; i < 100; i++ {
+		value[string(rune('a'+i%26))+string(rune('a'+i/26))] = i
+	}
+
+	first, err := CanonicalEncode(value)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	for i := 0; i < 20; i++ {
+		again, _ := CanonicalEncode(value)
+		if string(first) != string(again) {
+			t.Fatalf("expected stable encoding, got %s and %s", first, again)
+		}
+	}
+}
+
+func TestCanonicalEncodeErrors(t *testing.T) {
+	cycle := &canonicalCycle{}
+	cycle.Next = cycle
+	if _, err := CanonicalEncode(cycle); err == nil {
+		t.Error("expected error for cyclic value, got nil")
+	}
+
+	selfMap := map[string]any{}
+	selfMap["self"] = selfMap
+	if _, err := CanonicalEncode(selfMap); err == nil {
+		t.Error("expected error for map containing itself, got nil")
+	}
+
+	selfSlice := []any{nil}
+	selfSlice[0] = selfSlice
+	if _, err := CanonicalEncode(selfSlice); err == nil {
+		t.Error("expected error for slice containing itself, got nil")
+	}
+
+	// The same map twice is not a cycle
+	shared := map[string]int{"a": 1}
+	if _, err := CanonicalEncode([]any{shared, shared}); err != nil {
+		t.Errorf("expected no error for a repeated map, got %v", err)
+	}
+
+	if _, err := CanonicalEncode(func() {}); err == nil {
+		t.Error("expected error for func value, got nil")
+	}
+
+	_, err := CanonicalOptions{Unexported: ErrorOnUnexported}.Encode(canonicalUnexported{})
+	if err == nil {
+		t.Error("expected error for unexported field, got nil")
+	}
+}
+
+func TestHashStructMD5SortedKeysKeepsPrecision(t *testing.T) {
+	a, err := HashStructMD5SortedKeys(map[string]int64{"id": 1<<53 + 1})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	b, _ := HashStructMD5SortedKeys(map[string]int64{"id": 1 << 53})
+	if a == b {
+		t.Error("expected ids differing beyond float64 precision to hash differently")
+	}
+}
@@ -2,11 +2,18 @@ package keys
 
 import (
 	"crypto/md5"
+	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
-	"sort"
+	"fmt"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/zeebo/xxh3"
 )
 
+// HashFunc turns the parts of a query key into a cache key.
+type HashFunc func(data ...any) (string, error)
+
 // A wrapper around the JSON serialization function for simplicity
 func HashKeyJson(data ...any) (string, error) {
 	// Marshal the data directly to JSON
@@ -35,112 +42,98 @@ func HashKeyMD5(data ...any) (string, error) {
 	return hashString, nil
 }
 
-// HashStructMD5 takes any interface (struct or array), serializes it, and returns a stable MD5 hash.
-func HashStructMD5(data interface{}) (string, error) {
-	// Serialize the struct/array into JSON
-	jsonData, err := json.Marshal(data)
-	if err != nil {
-		return "", err
-	}
-
-	// Create a new MD5 hash
-	hash := md5.New()
-	_, err = hash.Write(jsonData)
+// HashKeySHA256 is like HashKeyMD5 but uses SHA-256, for when collisions must be practically impossible.
+func HashKeySHA256(data ...any) (string, error) {
+	jsonBytes, err := json.Marshal(data)
 	if err != nil {
 		return "", err
 	}
 
-	// Convert the hash to a hex string
-	hashString := hex.EncodeToString(hash.Sum(nil))
-	return hashString, nil
+	sum := sha256.Sum256(jsonBytes)
+	return hex.EncodeToString(sum[:]), nil
 }
 
-// HashStructMD5SortedKeys takes a struct/array, sorts the JSON keys, and returns a stable MD5 hash.
-func HashStructMD5SortedKeys(data interface{}) (string, error) {
-	// Serialize the struct/array into JSON with sorted keys
-	sortedJsonData, err := marshalWithSortedKeys(data)
+// HashKeyXXHash64 uses the non-cryptographic xxhash64, which is much faster than MD5 and
+// produces a 16 character key.
+func HashKeyXXHash64(data ...any) (string, error) {
+	jsonBytes, err := json.Marshal(data)
 	if err != nil {
 		return "", err
 	}
 
-	// Create an MD5 hash from the sorted JSON data
+	sum := xxhash.Sum64(jsonBytes)
+	return fmt.Sprintf("%016x", sum), nil
+}
 
-	hash := md5.New()
-	_, err = hash.Write(sortedJsonData)
+// HashKeyXXHash128 uses the 128 bit variant of xxh3, trading a longer key for a lower collision rate than HashKeyXXHash64.
+func HashKeyXXHash128(data ...any) (string, error) {
+	jsonBytes, err := json.Marshal(data)
 	if err != nil {
 		return "", err
 	}
 
-	// Convert the hash to a hex string
-	hashString := hex.EncodeToString(hash.Sum(nil))
-	return hashString, nil
+	sum := xxh3.Hash128(jsonBytes).Bytes()
+	return hex.EncodeToString(sum[:]), nil
 }
 
-// Some more complex hashing functions
-
-// sortKeys ensures that the keys of maps are sorted in JSON serialization.
-func sortKeys(data interface{}) (interface{}, error) {
-	switch v := data.(type) {
-	case map[string]interface{}:
-		// Create a new map with sorted keys
-		sortedMap := make(map[string]interface{})
-		keys := make([]string, 0, len(v))
-
-		// Collect all keys
-		for k := range v {
-			keys = append(keys, k)
-		}
-
-		// Sort keys alphabetically
-		sort.Strings(keys)
-
-		// Recursively sort the values
-		for _, k := range keys {
-			sortedValue, err := sortKeys(v[k])
-			if err != nil {
-				return nil, err
-			}
-			sortedMap[k] = sortedValue
+// WithPrefix wraps a HashFunc so that a leading string argument stays readable in front of the hash,
+// e.g. []any{"users", 42} becomes "users:<hash>". This makes keys easy to spot when debugging a store.
+func WithPrefix(hashFunction HashFunc) HashFunc {
+	return func(data ...any) (string, error) {
+		hash, err := hashFunction(data...)
+		if err != nil {
+			return "", err
 		}
 
-		return sortedMap, nil
-	case []interface{}:
-		// Recursively sort array elements
-		sortedArray := make([]interface{}, len(v))
-		for i, item := range v {
-			sortedItem, err := sortKeys(item)
-			if err != nil {
-				return nil, err
+		if len(data) > 0 {
+			if prefix, ok := data[0].(string); ok && prefix != "" {
+				return prefix + ":" + hash, nil
 			}
-			sortedArray[i] = sortedItem
 		}
-		return sortedArray, nil
-	default:
-		// For other types, return as is
-		return data, nil
+		return hash, nil
 	}
 }
 
-// marshalWithSortedKeys serializes the data with sorted keys for consistent hashing.
-func marshalWithSortedKeys(data interface{}) ([]byte, error) {
-	// Convert the struct or map to a map[string]interface{} using json.Unmarshal
-	jsonBytes, err := json.Marshal(data)
+// HashTag wraps tag in a Redis Cluster hash tag in front of key, so every key built with the same tag
+// lands in the same hash slot and can be used together in multi-key commands.
+func HashTag(tag string, key string) string {
+	return "{" + tag + "}" + key
+}
+
+// HashStructMD5 takes any interface (struct or array), serializes it, and returns a stable MD5 hash.
+func HashStructMD5(data interface{}) (string, error) {
+	// Serialize the struct/array into JSON
+	jsonData, err := json.Marshal(data)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 
-	var genericData interface{}
-	err = json.Unmarshal(jsonBytes, &genericData)
+	// Create a new MD5 hash
+	hash := md5.New()
+	_, err = hash.Write(jsonData)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 
-	// Sort the keys in the map or struct
-	sortedData, err := sortKeys(genericData)
+	// Convert the hash to a hex string
+	hashString := hex.EncodeToString(hash.Sum(nil))
+	return hashString, nil
+}
+
+// HashStructMD5SortedKeys takes a struct/array, encodes it canonically (see CanonicalEncode) and returns a stable MD5 hash.
+func HashStructMD5SortedKeys(data interface{}) (string, error) {
+	sortedData, err := CanonicalEncode(data)
+	if err != nil {
+		return "", err
+	}
+
+	hash := md5.New()
+	_, err = hash.Write(sortedData)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 
-	// Marshal the sorted data back to JSON
-	return json.Marshal(sortedData)
+	// Convert the hash to a hex string
+	hashString := hex.EncodeToString(hash.Sum(nil))
+	return hashString, nil
 }
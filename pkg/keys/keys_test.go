@@ -2,6 +2,7 @@ package keys
 
 import (
 	"crypto/md5"
+	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
 	"testing"
@@ -70,3 +71,74 @@ func TestHashKeyMD5(t *testing.T) {
 		t.Errorf("expected %s, got %s", expected, hashMD5)
 	}
 }
+
+func TestHashKeySHA256(t *testing.T) {
+	data := []interface{}{"string", 123}
+
+	expectedBytes, _ := json.Marshal(data)
+	sum := sha256.Sum256(expectedBytes)
+	expected := hex.EncodeToString(sum[:])
+
+	hash, err := HashKeySHA256("string", 123)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if hash != expected {
+		t.Errorf("expected %s, got %s", expected, hash)
+	}
+}
+
+func TestHashKeyXXHash(t *testing.T) {
+	tests := []struct {
+		name     string
+		hashFunc HashFunc
+		length   int
+	}{
+		{"xxhash64", HashKeyXXHash64, 16},
+		{"xxhash128", HashKeyXXHash128, 32},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			first, err := tt.hashFunc("string", 123, []string{"one", "two"})
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if len(first) != tt.length {
+				t.Errorf("expected a %d character hash, got %q", tt.length, first)
+			}
+
+			second, _ := tt.hashFunc("string", 123, []string{"one", "two"})
+			if first != second {
+				t.Errorf("expected stable hash, got %s and %s", first, second)
+			}
+
+			other, _ := tt.hashFunc("string", 124, []string{"one", "two"})
+			if first == other {
+				t.Errorf("expected different inputs to hash differently, both gave %s", first)
+			}
+		})
+	}
+}
+
+func TestWithPrefix(t *testing.T) {
+	hashFunc := WithPrefix(HashKeyXXHash64)
+
+	hash, err := hashFunc("users", 42)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	plain, _ := HashKeyXXHash64("users", 42)
+	if hash != "users:"+plain {
+		t.Errorf("expected users:%s, got %s", plain, hash)
+	}
+
+	// Without a leading string there is nothing readable to keep
+	hash, _ = hashFunc(42, "users")
+	plain, _ = HashKeyXXHash64(42, "users")
+	if hash != plain {
+		t.Errorf("expected %s, got %s", plain, hash)
+	}
+}
@@ -0,0 +1,126 @@
+// Package memo memoizes functions in a TieredCache, so results are shared across
+// tiers (and processes, when a remote store is configured) and refreshed with stale-while-revalidate.
+// Memoized functions get the values of the caller's context but not its cancellation, as stale
+// results are refreshed in the background after the call returned.
+package memo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/reksie/tieredcache/pkg/keys"
+	"github.com/reksie/tieredcache/pkg/tieredcache"
+	"golang.org/x/sync/singleflight"
+)
+
+type Options struct {
+	TieredCache *tieredcache.TieredCache
+	// Name identifies the memoized function and is used as the first part of the query key,
+	// so two functions with the same arguments never share entries.
+	Name  string
+	Fresh time.Duration
+	TTL   time.Duration
+	// Version is passed on to tieredcache.QueryOptions, bump it when the result type changes.
+	Version int
+	// KeyFunc derives a key from the arguments, defaults to keys.HashKeyCanonicalMD5.
+	KeyFunc keys.HashFunc
+}
+
+// memoizer holds what is shared by every call of a memoized function.
+type memoizer[R any] struct {
+	options Options
+	group   singleflight.Group
+}
+
+func newMemoizer[R any](opts Options) *memoizer[R] {
+	if opts.TieredCache == nil {
+		panic("memo: Options.TieredCache is required")
+	}
+	if opts.Name == "" {
+		panic("memo: Options.Name is required")
+	}
+	if opts.KeyFunc == nil {
+		opts.KeyFunc = keys.HashKeyCanonicalMD5
+	}
+	return &memoizer[R]{options: opts}
+}
+
+// call returns the cached result for args, calling fn on a miss or to refresh a stale result.
+// Concurrent calls for the same arguments share a single call to fn, and errors are never cached.
+//
+// fn gets ctx without its cancellation: stale results are refreshed in the background after the call
+// returned, usually once ctx is canceled, and a shared call should not fail because one caller gave up.
+func (m *memoizer[R]) call(ctx context.Context, fn func(ctx context.Context) (R, error), args ...any) (R, error) {
+	var zeroValue R
+
+	argsKey, err := m.options.KeyFunc(args...)
+	if err != nil {
+		return zeroValue, err
+	}
+
+	queryCtx := context.WithoutCancel(ctx)
+	queryFn := func() (R, error) {
+		value, err, _ := m.group.Do(argsKey, func() (any, error) {
+			return fn(queryCtx)
+		})
+		if err != nil {
+			return zeroValue, err
+		}
+		result, ok := value.(R)
+		if !ok {
+			return zeroValue, errors.New("memo: unexpected result type")
+		}
+		return result, nil
+	}
+
+	return tieredcache.Swr(tieredcache.QueryOptions[R]{
+		Context:       ctx,
+		TieredCache:   m.options.TieredCache,
+		QueryKey:      []any{m.options.Name, argsKey},
+		QueryFunction: queryFn,
+		Fresh:         m.options.Fresh,
+		TTL:           m.options.TTL,
+		Version:       m.options.Version,
+	})
+}
+
+// Memoize0 memoizes a function without arguments.
+func Memoize0[R any](opts Options, fn func(ctx context.Context) (R, error)) func(ctx context.Context) (R, error) {
+	m := newMemoizer[R](opts)
+	return func(ctx context.Context) (R, error) {
+		return m.call(ctx, func(ctx context.Context) (R, error) { return fn(ctx) })
+	}
+}
+
+// Memoize1 memoizes a function of one argument.
+func Memoize1[A, R any](opts Options, fn func(ctx context.Context, a A) (R, error)) func(ctx context.Context, a A) (R, error) {
+	m := newMemoizer[R](opts)
+	return func(ctx context.Context, a A) (R, error) {
+		return m.call(ctx, func(ctx context.Context) (R, error) { return fn(ctx, a) }, a)
+	}
+}
+
+// Memoize2 memoizes a function of two arguments.
+func Memoize2[A, B, R any](opts Options, fn func(ctx context.Context, a A, b B) (R, error)) func(ctx context.Context, a A, b B) (R, error) {
+	m := newMemoizer[R](opts)
+	return func(ctx context.Context, a A, b B) (R, error) {
+		return m.call(ctx, func(ctx context.Context) (R, error) { return fn(ctx, a, b) }, a, b)
+	}
+}
+
+// Memoize3 memoizes a function of three arguments.
+func Memoize3[A, B, C, R any](opts Options, fn func(ctx context.Context, a A, b B, c C) (R, error)) func(ctx context.Context, a A, b B, c C) (R, error) {
+	m := newMemoizer[R](opts)
+	return func(ctx context.Context, a A, b B, c C) (R, error) {
+		return m.call(ctx, func(ctx context.Context) (R, error) { return fn(ctx, a, b, c) }, a, b, c)
+	}
+}
+
+// Memoize4 memoizes a function of four arguments.
+func Memoize4[A, B, C, D, R any](opts Options, fn func(ctx context.Context, a A, b B, c C, d D) (R, error)) func(ctx context.Context, a A, b B, c C, d D) (R, error) {
+	m := newMemoizer[R](opts)
+	return func(ctx context.Context, a A, b B, c C, d D) (R, error) {
+		return m.call(ctx, func(ctx context.Context) (R, error) { return fn(ctx, a, b, c, d) }, a, b, c, d)
+	}
+}
@@ -0,0 +1,165 @@
+package memo
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/allegro/bigcache/v3"
+	"github.com/reksie/tieredcache/pkg/interfaces"
+	"github.com/reksie/tieredcache/pkg/stores"
+	"github.com/reksie/tieredcache/pkg/tieredcache"
+	"github.com/stretchr/testify/assert"
+)
+
+func createTestCache(t *testing.T) *tieredcache.TieredCache {
+	bigcacheInstance, err := bigcache.New(context.Background(), bigcache.DefaultConfig(10*time.Minute))
+	assert.NoError(t, err)
+
+	cache := tieredcache.NewTieredCache(time.Minute, []interfaces.CacheStore{stores.CreateMemoryStore("memory", bigcacheInstance)})
+	t.Cleanup(func() { cache.Close() })
+	return cache
+}
+
+func TestMemoize1(t *testing.T) {
+	ctx := context.Background()
+	var calls int32
+
+	double := Memoize1(Options{TieredCache: createTestCache(t), Name: "double", TTL: time.Minute}, func(ctx context.Context, x int) (int, error) {
+		atomic.AddInt32(&calls, 1)
+		return x * 2, nil
+	})
+
+	result, err := double(ctx, 5)
+	assert.NoError(t, err)
+	assert.Equal(t, 10, result)
+
+	result, err = double(ctx, 5)
+	assert.NoError(t, err)
+	assert.Equal(t, 10, result)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	result, err = double(ctx, 6)
+	assert.NoError(t, err)
+	assert.Equal(t, 12, result)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestMemoize2Struct(t *testing.T) {
+	ctx := context.Background()
+	type Filter struct {
+		Tags map[string]bool
+	}
+	type Result struct {
+		Name  string
+		Count int
+	}
+	var calls int32
+
+	search := Memoize2(Options{TieredCache: createTestCache(t), Name: "search", TTL: time.Minute}, func(ctx context.Context, name string, filter Filter) (Result, error) {
+		atomic.AddInt32(&calls, 1)
+		return Result{Name: name, Count: len(filter.Tags)}, nil
+	})
+
+	result, err := search(ctx, "test", Filter{Tags: map[string]bool{"a": true, "b": true}})
+	assert.NoError(t, err)
+	assert.Equal(t, Result{Name: "test", Count: 2}, result)
+
+	result, err = search(ctx, "test", Filter{Tags: map[string]bool{"b": true, "a": true}})
+	assert.NoError(t, err)
+	assert.Equal(t, Result{Name: "test", Count: 2}, result)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestMemoizeRefreshAfterCancel(t *testing.T) {
+	var calls atomic.Int32
+	answer := Memoize0(Options{TieredCache: createTestCache(t), Name: "answer", Fresh: time.Millisecond, TTL: time.Minute}, func(ctx context.Context) (int, error) {
+		// Gives the caller time to cancel before the result is returned
+		time.Sleep(5 * time.Millisecond)
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		return int(calls.Add(1)), nil
+	})
+
+	result, err := answer(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result)
+	time.Sleep(5 * time.Millisecond)
+
+	// Stale, so the refresh runs in the background after the caller canceled its context
+	ctx, cancel := context.WithCancel(context.Background())
+	result, err = answer(ctx)
+	cancel()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result)
+
+	assert.Eventually(t, func() bool {
+		return calls.Load() == 2
+	}, time.Second, time.Millisecond)
+}
+
+func TestMemoizeDoesNotCacheErrors(t *testing.T) {
+	ctx := context.Background()
+	var calls int32
+
+	fail := Memoize1(Options{TieredCache: createTestCache(t), Name: "fail", TTL: time.Minute}, func(ctx context.Context, x int) (int, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			return 0, errors.New("boom")
+		}
+		return x, nil
+	})
+
+	_, err := fail(ctx, 1)
+	assert.Error(t, err)
+
+	result, err := fail(ctx, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestMemoizeNamesDoNotCollide(t *testing.T) {
+	ctx := context.Background()
+	cache := createTestCache(t)
+
+	double := Memoize1(Options{TieredCache: cache, Name: "double", TTL: time.Minute}, func(ctx context.Context, x int) (int, error) {
+		return x * 2, nil
+	})
+	triple := Memoize1(Options{TieredCache: cache, Name: "triple", TTL: time.Minute}, func(ctx context.Context, x int) (int, error) {
+		return x * 3, nil
+	})
+
+	result, _ := double(ctx, 2)
+	assert.Equal(t, 4, result)
+	result, _ = triple(ctx, 2)
+	assert.Equal(t, 6, result)
+}
+
+func TestMemoizeSingleflight(t *testing.T) {
+	ctx := context.Background()
+	var calls int32
+
+	slow := Memoize1(Options{TieredCache: createTestCache(t), Name: "slow", TTL: time.Minute}, func(ctx context.Context, x int) (int, error) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(50 * time.Millisecond)
+		return x, nil
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, err := slow(ctx, 7)
+			assert.NoError(t, err)
+			assert.Equal(t, 7, result)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
@@ -0,0 +1,80 @@
+// Package metrics records cache measurements. TieredCache reports to a Recorder set with
+// tieredcache.WithMetrics, and Instrument measures any store on its own. Recorders for
+// Prometheus live in the prometheus subpackage, so importing this package pulls in no client library.
+package metrics
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/reksie/tieredcache/pkg/interfaces"
+	"github.com/reksie/tieredcache/pkg/stores"
+)
+
+// Result is the outcome of a store operation.
+type Result string
+
+const (
+	ResultHit   Result = "hit"
+	ResultMiss  Result = "miss"
+	ResultOK    Result = "ok"
+	ResultError Result = "error"
+)
+
+// SwrOutcome is how Swr found a query in the cache.
+type SwrOutcome string
+
+const (
+	SwrFresh SwrOutcome = "fresh"
+	SwrStale SwrOutcome = "stale"
+	SwrMiss  SwrOutcome = "miss"
+)
+
+// Recorder receives measurements, identifying stores by Name(). Implementations must be safe for concurrent use.
+type Recorder interface {
+	// StoreOperation records an operation on a store and how long it took.
+	StoreOperation(store string, op stores.Op, result Result, duration time.Duration)
+	// EntrySize records the encoded size in bytes of a value written to a store.
+	EntrySize(store string, bytes int)
+	// SwrLookup records how Swr found a query in the cache.
+	SwrLookup(outcome SwrOutcome)
+	// BackgroundRefresh records the result of a refresh of a stale entry, err is nil on success.
+	BackgroundRefresh(err error)
+}
+
+// Noop discards every measurement, it is the default of TieredCache.
+type Noop struct{}
+
+func (Noop) StoreOperation(store string, op stores.Op, result Result, duration time.Duration) {}
+func (Noop) EntrySize(store string, bytes int)                                                {}
+func (Noop) SwrLookup(outcome SwrOutcome)                                                     {}
+func (Noop) BackgroundRefresh(err error)                                                      {}
+
+// ResultOf classifies the error of an operation, misses being errors wrapping interfaces.ErrNotFound.
+func ResultOf(op stores.Op, err error) Result {
+	switch {
+	case err == nil && (op == stores.OpGet || op == stores.OpGetMany):
+		return ResultHit
+	case err == nil:
+		return ResultOK
+	case errors.Is(err, interfaces.ErrNotFound):
+		return ResultMiss
+	default:
+		return ResultError
+	}
+}
+
+// Instrument records the operations of a store. Do not use it on the tiers of a TieredCache
+// that has a recorder, they are measured already and would be counted twice.
+func Instrument(recorder Recorder) stores.Middleware {
+	return func(store interfaces.CacheStore) interfaces.CacheStore {
+		name := store.Name()
+		return stores.Intercept(func(ctx context.Context, op stores.Op, key string, next func(ctx context.Context) error) error {
+			start := time.Now()
+			err := next(ctx)
+			recorder.StoreOperation(name, op, ResultOf(op, err), time.Since(start))
+			return err
+		})(store)
+	}
+}
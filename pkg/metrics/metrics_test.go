@@ -0,0 +1,58 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/reksie/tieredcache/pkg/interfaces"
+	"github.com/reksie/tieredcache/pkg/stores"
+	"github.com/stretchr/testify/assert"
+)
+
+type operation struct {
+	store  string
+	op     stores.Op
+	result Result
+}
+
+type fakeRecorder struct {
+	Noop
+	mu         sync.Mutex
+	operations []operation
+}
+
+func (r *fakeRecorder) StoreOperation(store string, op stores.Op, result Result, duration time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.operations = append(r.operations, operation{store, op, result})
+}
+
+func TestResultOf(t *testing.T) {
+	assert.Equal(t, ResultHit, ResultOf(stores.OpGet, nil))
+	assert.Equal(t, ResultOK, ResultOf(stores.OpSet, nil))
+	assert.Equal(t, ResultMiss, ResultOf(stores.OpGet, interfaces.ErrNotFound))
+	assert.Equal(t, ResultError, ResultOf(stores.OpSet, errors.New("connection refused")))
+}
+
+func TestInstrument(t *testing.T) {
+	ctx := context.Background()
+	recorder := &fakeRecorder{}
+	store := stores.Chain(stores.CreateLocalStore("local", stores.LocalStoreConfig{}), Instrument(recorder))
+
+	assert.NoError(t, store.Set(ctx, "key1", "value1", time.Minute))
+	_, err := store.Get(ctx, "key1")
+	assert.NoError(t, err)
+	_, err = store.Get(ctx, "non_existent_key")
+	assert.ErrorIs(t, err, interfaces.ErrNotFound)
+	assert.NoError(t, store.Delete(ctx, "key1"))
+
+	assert.Equal(t, []operation{
+		{"local", stores.OpSet, ResultOK},
+		{"local", stores.OpGet, ResultHit},
+		{"local", stores.OpGet, ResultMiss},
+		{"local", stores.OpDelete, ResultOK},
+	}, recorder.operations)
+}
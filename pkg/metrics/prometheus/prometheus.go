@@ -0,0 +1,117 @@
+// Package prometheus implements metrics.Recorder with Prometheus metrics.
+package prometheus
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/reksie/tieredcache/pkg/metrics"
+	"github.com/reksie/tieredcache/pkg/stores"
+)
+
+type Options struct {
+	// Namespace prefixes every metric name, defaults to "tieredcache".
+	Namespace string
+	// DurationBuckets are the buckets of the operation latency histogram in seconds,
+	// default from 100µs to about 1.6s.
+	DurationBuckets []float64
+	// SizeBuckets are the buckets of the entry size histogram in bytes, default from 64B to 16MiB.
+	SizeBuckets []float64
+}
+
+// Recorder is a metrics.Recorder and a prometheus.Collector; register it to expose:
+//
+//	<namespace>_store_operations_total{store, op, result}
+//	<namespace>_store_operation_duration_seconds{store, op}
+//	<namespace>_entry_size_bytes{store}
+//	<namespace>_swr_lookups_total{outcome}
+//	<namespace>_background_refreshes_total{result}
+type Recorder struct {
+	operations *prometheus.CounterVec
+	durations  *prometheus.HistogramVec
+	sizes      *prometheus.HistogramVec
+	lookups    *prometheus.CounterVec
+	refreshes  *prometheus.CounterVec
+}
+
+var _ metrics.Recorder = (*Recorder)(nil)
+var _ prometheus.Collector = (*Recorder)(nil)
+
+func NewRecorder(opts Options) *Recorder {
+	if opts.Namespace == "" {
+		opts.Namespace = "tieredcache"
+	}
+	if opts.DurationBuckets == nil {
+		opts.DurationBuckets = prometheus.ExponentialBuckets(0.0001, 2, 15)
+	}
+	if opts.SizeBuckets == nil {
+		opts.SizeBuckets = prometheus.ExponentialBuckets(64, 4, 10)
+	}
+
+	return &Recorder{
+		operations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: opts.Namespace,
+			Name:      "store_operations_total",
+			Help:      "Operations on cache stores by result.",
+		}, []string{"store", "op", "result"}),
+		durations: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: opts.Namespace,
+			Name:      "store_operation_duration_seconds",
+			Help:      "Latency of operations on cache stores.",
+			Buckets:   opts.DurationBuckets,
+		}, []string{"store", "op"}),
+		sizes: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: opts.Namespace,
+			Name:      "entry_size_bytes",
+			Help:      "Encoded size of values written to cache stores.",
+			Buckets:   opts.SizeBuckets,
+		}, []string{"store"}),
+		lookups: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: opts.Namespace,
+			Name:      "swr_lookups_total",
+			Help:      "Swr lookups by how the cached entry was found.",
+		}, []string{"outcome"}),
+		refreshes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: opts.Namespace,
+			Name:      "background_refreshes_total",
+			Help:      "Background refreshes of stale entries by result.",
+		}, []string{"result"}),
+	}
+}
+
+func (r *Recorder) StoreOperation(store string, op stores.Op, result metrics.Result, duration time.Duration) {
+	r.operations.WithLabelValues(store, string(op), string(result)).Inc()
+	r.durations.WithLabelValues(store, string(op)).Observe(duration.Seconds())
+}
+
+func (r *Recorder) EntrySize(store string, bytes int) {
+	r.sizes.WithLabelValues(store).Observe(float64(bytes))
+}
+
+func (r *Recorder) SwrLookup(outcome metrics.SwrOutcome) {
+	r.lookups.WithLabelValues(string(outcome)).Inc()
+}
+
+func (r *Recorder) BackgroundRefresh(err error) {
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	r.refreshes.WithLabelValues(result).Inc()
+}
+
+func (r *Recorder) Describe(ch chan<- *prometheus.Desc) {
+	r.operations.Describe(ch)
+	r.durations.Describe(ch)
+	r.sizes.Describe(ch)
+	r.lookups.Describe(ch)
+	r.refreshes.Describe(ch)
+}
+
+func (r *Recorder) Collect(ch chan<- prometheus.Metric) {
+	r.operations.Collect(ch)
+	r.durations.Collect(ch)
+	r.sizes.Collect(ch)
+	r.lookups.Collect(ch)
+	r.refreshes.Collect(ch)
+}
@@ -0,0 +1,44 @@
+package prometheus
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/reksie/tieredcache/pkg/metrics"
+	"github.com/reksie/tieredcache/pkg/stores"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecorder(t *testing.T) {
+	recorder := NewRecorder(Options{})
+	registry := prometheus.NewRegistry()
+	assert.NoError(t, registry.Register(recorder))
+
+	recorder.StoreOperation("memory", stores.OpGet, metrics.ResultHit, time.Millisecond)
+	recorder.StoreOperation("memory", stores.OpGet, metrics.ResultMiss, time.Millisecond)
+	recorder.StoreOperation("memory", stores.OpGet, metrics.ResultHit, time.Millisecond)
+	recorder.EntrySize("memory", 100)
+	recorder.SwrLookup(metrics.SwrStale)
+	recorder.BackgroundRefresh(nil)
+	recorder.BackgroundRefresh(errors.New("origin down"))
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(recorder.operations.WithLabelValues("memory", "get", "hit")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(recorder.operations.WithLabelValues("memory", "get", "miss")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(recorder.lookups.WithLabelValues("stale")))
+
+	err := testutil.GatherAndCompare(registry, strings.NewReader(`
+# HELP tieredcache_background_refreshes_total Background refreshes of stale entries by result.
+# TYPE tieredcache_background_refreshes_total counter
+tieredcache_background_refreshes_total{result="failure"} 1
+tieredcache_background_refreshes_total{result="success"} 1
+`), "tieredcache_background_refreshes_total")
+	assert.NoError(t, err)
+
+	count, err := testutil.GatherAndCount(registry, "tieredcache_store_operation_duration_seconds", "tieredcache_entry_size_bytes")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+}
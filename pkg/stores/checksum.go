@@ -0,0 +1,164 @@
+package stores
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/reksie/tieredcache/pkg/interfaces"
+)
+
+// Checksum is the algorithm protecting an entry. It is written as the first byte of the envelope, using
+// values apart from the compression flags and encryption version so a misconfigured stack is detected.
+type Checksum byte
+
+const (
+	ChecksumCRC32C   Checksum = 0x10
+	ChecksumXXHash64 Checksum = 0x11
+)
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+type ChecksumStoreConfig struct {
+	// Checksum defaults to CRC32C.
+	Checksum Checksum
+	// Codec encodes values before the checksum is computed, defaults to JSONCodec.
+	Codec Codec
+}
+
+type checksumStore struct {
+	store  interfaces.CacheStore
+	config ChecksumStoreConfig
+}
+
+// CreateChecksumStore wraps a store so every value carries a checksum of its encoded bytes.
+// Entries that are truncated, altered or were not written by a checksum store fail with interfaces.ErrCorrupt.
+func CreateChecksumStore(store interfaces.CacheStore, config ChecksumStoreConfig) interfaces.CacheStore {
+	if config.Checksum == 0 {
+		config.Checksum = ChecksumCRC32C
+	}
+	if config.Codec == nil {
+		config.Codec = JSONCodec{}
+	}
+
+	return &checksumStore{
+		store:  store,
+		config: config,
+	}
+}
+
+func (c *checksumStore) Name() string {
+	return c.store.Name()
+}
+
+func (c *checksumStore) Set(ctx context.Context, key string, value any, ttl time.Duration) error {
+	data, err := c.config.Codec.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	envelope := []byte{byte(c.config.Checksum)}
+	switch c.config.Checksum {
+	case ChecksumCRC32C:
+		envelope = binary.BigEndian.AppendUint32(envelope, crc32.Checksum(data, crc32cTable))
+	case ChecksumXXHash64:
+		envelope = binary.BigEndian.AppendUint64(envelope, xxhash.Sum64(data))
+	default:
+		return fmt.Errorf("unknown checksum %d", c.config.Checksum)
+	}
+	envelope = append(envelope, data...)
+
+	return c.store.Set(ctx, key, envelope, ttl)
+}
+
+func (c *checksumStore) Get(ctx context.Context, key string) (any, error) {
+	value, err := c.store.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return c.decode(value)
+}
+
+func (c *checksumStore) GetWithTTL(ctx context.Context, key string) (any, time.Duration, error) {
+	value, ttl, err := getWithTTL(ctx, c.store, key)
+	if err != nil {
+		return nil, 0, err
+	}
+	decoded, err := c.decode(value)
+	if err != nil {
+		return nil, 0, err
+	}
+	return decoded, ttl, nil
+}
+
+func (c *checksumStore) decode(value any) (any, error) {
+	envelope, err := envelopeBytes(value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", interfaces.ErrCorrupt, err)
+	}
+
+	data, err := verifyChecksum(envelope)
+	if err != nil {
+		return nil, err
+	}
+
+	decoded, err := c.config.Codec.Unmarshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", interfaces.ErrCorrupt, err)
+	}
+	return decoded, nil
+}
+
+func (c *checksumStore) Delete(ctx context.Context, key string) error {
+	return c.store.Delete(ctx, key)
+}
+
+func (c *checksumStore) Clear(ctx context.Context) error {
+	return c.store.Clear(ctx)
+}
+
+func (c *checksumStore) Close() error {
+	return c.store.Close()
+}
+
+func (c *checksumStore) Stats(ctx context.Context) (interfaces.StoreStats, error) {
+	return storeStats(ctx, c.store)
+}
+
+func (c *checksumStore) OnEvict(fn func(key string)) {
+	onEvict(c.store, fn)
+}
+
+// verifyChecksum checks an envelope written with any of the checksums and returns its data.
+func verifyChecksum(envelope []byte) ([]byte, error) {
+	if len(envelope) == 0 {
+		return nil, fmt.Errorf("%w: empty value", interfaces.ErrCorrupt)
+	}
+
+	var valid bool
+	var data []byte
+	switch Checksum(envelope[0]) {
+	case ChecksumCRC32C:
+		if len(envelope) < 5 {
+			return nil, fmt.Errorf("%w: truncated value", interfaces.ErrCorrupt)
+		}
+		data = envelope[5:]
+		valid = binary.BigEndian.Uint32(envelope[1:5]) == crc32.Checksum(data, crc32cTable)
+	case ChecksumXXHash64:
+		if len(envelope) < 9 {
+			return nil, fmt.Errorf("%w: truncated value", interfaces.ErrCorrupt)
+		}
+		data = envelope[9:]
+		valid = binary.BigEndian.Uint64(envelope[1:9]) == xxhash.Sum64(data)
+	default:
+		return nil, fmt.Errorf("%w: unknown checksum %d", interfaces.ErrCorrupt, envelope[0])
+	}
+
+	if !valid {
+		return nil, fmt.Errorf("%w: checksum mismatch", interfaces.ErrCorrupt)
+	}
+	return data, nil
+}
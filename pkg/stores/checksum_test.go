@@ -0,0 +1,78 @@
+package stores
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/reksie/tieredcache/pkg/interfaces"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChecksumStoreSetGet(t *testing.T) {
+	ctx := context.Background()
+
+	for _, checksum := range []Checksum{ChecksumCRC32C, ChecksumXXHash64} {
+		inner := CreateLocalStore("local", LocalStoreConfig{})
+		store := CreateChecksumStore(inner, ChecksumStoreConfig{Checksum: checksum})
+		assert.Equal(t, "local", store.Name())
+
+		assert.NoError(t, store.Set(ctx, "key1", map[string]any{"name": "John"}, time.Minute))
+
+		raw, err := inner.Get(ctx, "key1")
+		assert.NoError(t, err)
+		assert.Equal(t, byte(checksum), raw.([]byte)[0])
+
+		value, err := store.Get(ctx, "key1")
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]any{"name": "John"}, value)
+	}
+}
+
+func TestChecksumStoreCorruption(t *testing.T) {
+	ctx := context.Background()
+	inner := CreateLocalStore("local", LocalStoreConfig{})
+	store := CreateChecksumStore(inner, ChecksumStoreConfig{})
+
+	assert.NoError(t, store.Set(ctx, "key1", "value1", time.Minute))
+	raw, err := inner.Get(ctx, "key1")
+	assert.NoError(t, err)
+	envelope := raw.([]byte)
+
+	flipped := append([]byte(nil), envelope...)
+	flipped[len(flipped)-1] ^= 0xff
+
+	for name, value := range map[string]any{
+		"flipped":   flipped,
+		"truncated": envelope[:3],
+		"empty":     []byte{},
+		"foreign":   []byte(`"value1"`),
+		"type":      42,
+	} {
+		assert.NoError(t, inner.Set(ctx, "key1", value, time.Minute))
+		_, err := store.Get(ctx, "key1")
+		assert.ErrorIs(t, err, interfaces.ErrCorrupt, name)
+	}
+
+	// Misses are not corruption
+	_, err = store.Get(ctx, "non_existent_key")
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, interfaces.ErrCorrupt)
+}
+
+func TestChecksumStoreThroughJSONStore(t *testing.T) {
+	ctx := context.Background()
+	cache, err := createTestCache()
+	assert.NoError(t, err)
+	store := CreateChecksumStore(CreateMemoryStore("memory", cache), ChecksumStoreConfig{})
+
+	assert.NoError(t, store.Set(ctx, "key1", "value1", time.Minute))
+	value, err := store.Get(ctx, "key1")
+	assert.NoError(t, err)
+	assert.Equal(t, "value1", value)
+
+	// Undecodable bytes in bigcache itself are reported as corrupt too
+	assert.NoError(t, cache.Set("key2", []byte("{truncated")))
+	_, err = store.Get(ctx, "key2")
+	assert.ErrorIs(t, err, interfaces.ErrCorrupt)
+}
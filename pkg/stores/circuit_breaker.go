@@ -0,0 +1,271 @@
+package stores
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/reksie/tieredcache/pkg/interfaces"
+)
+
+// ErrCircuitOpen is returned for reads while the circuit breaker is open. It wraps interfaces.ErrNotFound,
+// so TieredCache moves on to the next tier without logging or counting an error.
+var ErrCircuitOpen = fmt.Errorf("%w: circuit breaker is open", interfaces.ErrNotFound)
+
+type CircuitState int
+
+const (
+	// CircuitClosed lets every operation through.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen rejects every operation without calling the store.
+	CircuitOpen
+	// CircuitHalfOpen lets a few probes through to find out whether the store recovered.
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+type CircuitBreakerConfig struct {
+	// FailureRate is the share of failed operations in a window that opens the circuit, defaults to 0.5.
+	FailureRate float64
+	// MinRequests is the number of operations a window needs before it can open the circuit, defaults to 10.
+	MinRequests int
+	// Window is how long failures are counted for, defaults to ten seconds.
+	Window time.Duration
+	// SlowCall counts operations taking longer as failures, disabled when 0.
+	SlowCall time.Duration
+	// OpenDuration is how long the circuit stays open before probing, defaults to five seconds.
+	OpenDuration time.Duration
+	// HalfOpenProbes is the number of successful probes that close the circuit, defaults to 1.
+	HalfOpenProbes int
+	// QueueSize is the number of invalidations kept while the circuit is open and replayed once it closes.
+	// They are dropped when 0, or once the queue is full, leaving the store with outdated entries until they expire.
+	QueueSize int
+	// ReplayTimeout bounds each invalidation replayed once the circuit closes, defaults to five seconds.
+	ReplayTimeout time.Duration
+	// OnStateChange is called on every state change, once the breaker is unlocked so it may use the store.
+	// Changes made by concurrent operations may be reported out of order.
+	OnStateChange func(from, to CircuitState)
+}
+
+// CircuitBreaker tracks the health of a store, see WithCircuitBreaker.
+type CircuitBreaker struct {
+	config CircuitBreakerConfig
+
+	mu          sync.Mutex
+	state       CircuitState
+	windowStart time.Time
+	requests    int
+	failures    int
+	openedAt    time.Time
+	probes      int
+	successes   int
+	// queue holds the invalidations to replay once the circuit closes
+	queue []func(ctx context.Context) error
+	// changes holds the state changes to report once unlocked
+	changes [][2]CircuitState
+}
+
+func NewCircuitBreaker(config CircuitBreakerConfig) *CircuitBreaker {
+	if config.FailureRate <= 0 {
+		config.FailureRate = 0.5
+	}
+	if config.MinRequests <= 0 {
+		config.MinRequests = 10
+	}
+	if config.Window <= 0 {
+		config.Window = 10 * time.Second
+	}
+	if config.OpenDuration <= 0 {
+		config.OpenDuration = 5 * time.Second
+	}
+	if config.HalfOpenProbes <= 0 {
+		config.HalfOpenProbes = 1
+	}
+	if config.ReplayTimeout <= 0 {
+		config.ReplayTimeout = 5 * time.Second
+	}
+
+	return &CircuitBreaker{
+		config:      config,
+		windowStart: time.Now(),
+	}
+}
+
+// WithCircuitBreaker stops calling a store that keeps failing or is too slow. While the circuit is open,
+// reads fail immediately with ErrCircuitOpen, which TieredCache treats like a miss, and writes report
+// success so they do not fail the writes to the other tiers. Misses and corrupt entries are not failures.
+//
+// Writes made while the circuit is open are not replayed, as they could overwrite newer values written
+// once it closed and would restart their TTL. Instead, Set and Delete queue a Delete of their key and
+// Clear queues a Clear, so the store drops the entries it missed updates for once it closes. The replay
+// runs in the background and may remove values written right after the circuit closed, which only
+// costs a miss. SetMany does not say which keys it writes, so it is dropped, and the store may return
+// the values it held before until they expire; so does every write once the queue is full.
+func WithCircuitBreaker(breaker *CircuitBreaker) Middleware {
+	return func(store interfaces.CacheStore) interfaces.CacheStore {
+		return Intercept(func(ctx context.Context, op Op, key string, next func(ctx context.Context) error) error {
+			if !breaker.allow() {
+				switch op {
+				case OpSet, OpDelete:
+					breaker.enqueue(func(ctx context.Context) error {
+						return store.Delete(ctx, key)
+					})
+				case OpClear:
+					breaker.enqueue(store.Clear)
+				}
+				if op.IsWrite() {
+					return nil
+				}
+				return ErrCircuitOpen
+			}
+
+			start := time.Now()
+			err := next(ctx)
+			breaker.record(err, time.Since(start))
+			return err
+		})(store)
+	}
+}
+
+// State returns the current state, moving an open circuit to half-open once OpenDuration has passed.
+func (b *CircuitBreaker) State() CircuitState {
+	b.mu.Lock()
+	defer b.unlock()
+	b.checkOpen(time.Now())
+	return b.state
+}
+
+func (b *CircuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.unlock()
+
+	b.checkOpen(time.Now())
+	switch b.state {
+	case CircuitOpen:
+		return false
+	case CircuitHalfOpen:
+		if b.probes >= b.config.HalfOpenProbes {
+			return false
+		}
+		b.probes++
+	}
+	return true
+}
+
+func (b *CircuitBreaker) record(err error, duration time.Duration) {
+	failed := err != nil &&
+		!errors.Is(err, interfaces.ErrNotFound) &&
+		!errors.Is(err, interfaces.ErrCorrupt) &&
+		!errors.Is(err, context.Canceled)
+	if b.config.SlowCall > 0 && duration > b.config.SlowCall {
+		failed = true
+	}
+
+	b.mu.Lock()
+	defer b.unlock()
+
+	now := time.Now()
+	switch b.state {
+	case CircuitHalfOpen:
+		if failed {
+			b.open(now)
+			return
+		}
+		b.successes++
+		if b.successes >= b.config.HalfOpenProbes {
+			b.close(now)
+		}
+	case CircuitClosed:
+		if now.Sub(b.windowStart) > b.config.Window {
+			b.resetWindow(now)
+		}
+		b.requests++
+		if failed {
+			b.failures++
+		}
+		if b.requests >= b.config.MinRequests && float64(b.failures)/float64(b.requests) >= b.config.FailureRate {
+			b.open(now)
+		}
+	}
+}
+
+func (b *CircuitBreaker) enqueue(invalidate func(ctx context.Context) error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.queue) < b.config.QueueSize {
+		b.queue = append(b.queue, invalidate)
+	}
+}
+
+func (b *CircuitBreaker) checkOpen(now time.Time) {
+	if b.state == CircuitOpen && now.Sub(b.openedAt) >= b.config.OpenDuration {
+		b.probes = 0
+		b.successes = 0
+		b.setState(CircuitHalfOpen)
+	}
+}
+
+func (b *CircuitBreaker) open(now time.Time) {
+	b.openedAt = now
+	b.setState(CircuitOpen)
+}
+
+func (b *CircuitBreaker) close(now time.Time) {
+	b.resetWindow(now)
+	b.setState(CircuitClosed)
+
+	if len(b.queue) > 0 {
+		queue := b.queue
+		b.queue = nil
+		// Replayed in order, in the background so the probe that closed the circuit is not held up
+		timeout := b.config.ReplayTimeout
+		go func() {
+			for _, invalidate := range queue {
+				ctx, cancel := context.WithTimeout(context.Background(), timeout)
+				invalidate(ctx)
+				cancel()
+			}
+		}()
+	}
+}
+
+func (b *CircuitBreaker) resetWindow(now time.Time) {
+	b.windowStart = now
+	b.requests = 0
+	b.failures = 0
+}
+
+func (b *CircuitBreaker) setState(state CircuitState) {
+	if b.state == state {
+		return
+	}
+	b.changes = append(b.changes, [2]CircuitState{b.state, state})
+	b.state = state
+}
+
+// unlock releases the breaker, then reports the state changes made while it was held.
+func (b *CircuitBreaker) unlock() {
+	changes := b.changes
+	b.changes = nil
+	b.mu.Unlock()
+
+	if b.config.OnStateChange != nil {
+		for _, change := range changes {
+			b.config.OnStateChange(change[0], change[1])
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package stores
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/reksie/tieredcache/pkg/interfaces"
+	"github.com/stretchr/testify/assert"
+)
+
+// switchStore fails every operation while down is set.
+type switchStore struct {
+	interfaces.CacheStore
+	mu    sync.Mutex
+	down  bool
+	calls int
+}
+
+func (s *switchStore) setDown(down bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.down = down
+}
+
+func (s *switchStore) check() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+	if s.down {
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+func (s *switchStore) Get(ctx context.Context, key string) (any, error) {
+	if err := s.check(); err != nil {
+		return nil, err
+	}
+	return s.CacheStore.Get(ctx, key)
+}
+
+func (s *switchStore) Set(ctx context.Context, key string, value any, ttl time.Duration) error {
+	if err := s.check(); err != nil {
+		return err
+	}
+	return s.CacheStore.Set(ctx, key, value, ttl)
+}
+
+func TestCircuitBreakerOpensAndRecovers(t *testing.T) {
+	ctx := context.Background()
+	var transitions []string
+	var breaker *CircuitBreaker
+	breaker = NewCircuitBreaker(CircuitBreakerConfig{
+		MinRequests:  4,
+		OpenDuration: 20 * time.Millisecond,
+		OnStateChange: func(from, to CircuitState) {
+			transitions = append(transitions, from.String()+"->"+to.String())
+			// Called once the breaker is unlocked, so this does not deadlock
+			breaker.State()
+		},
+	})
+	inner := &switchStore{CacheStore: CreateLocalStore("local", LocalStoreConfig{})}
+	store := Chain(inner, WithCircuitBreaker(breaker))
+
+	assert.NoError(t, store.Set(ctx, "key1", "value1", time.Minute))
+
+	// Misses do not count as failures
+	for i := 0; i < 4; i++ {
+		_, err := store.Get(ctx, "non_existent_key")
+		assert.ErrorIs(t, err, interfaces.ErrNotFound)
+	}
+	assert.Equal(t, CircuitClosed, breaker.State())
+
+	// Misses are successes in the failure rate, 6 of 11 operations failed
+	inner.setDown(true)
+	for i := 0; i < 6; i++ {
+		store.Get(ctx, "key1")
+	}
+	assert.Equal(t, CircuitOpen, breaker.State())
+
+	// Open, so the store is not called
+	calls := inner.calls
+	_, err := store.Get(ctx, "key1")
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	// A miss for TieredCache, which moves on to the next tier quietly
+	assert.ErrorIs(t, err, interfaces.ErrNotFound)
+	assert.Equal(t, calls, inner.calls)
+
+	// A failed probe opens the circuit again
+	time.Sleep(25 * time.Millisecond)
+	assert.Equal(t, CircuitHalfOpen, breaker.State())
+	_, err = store.Get(ctx, "key1")
+	assert.Error(t, err)
+	assert.Equal(t, CircuitOpen, breaker.State())
+
+	// A successful probe closes it
+	inner.setDown(false)
+	time.Sleep(25 * time.Millisecond)
+	value, err := store.Get(ctx, "key1")
+	assert.NoError(t, err)
+	assert.Equal(t, "value1", value)
+	assert.Equal(t, CircuitClosed, breaker.State())
+
+	assert.Equal(t, []string{"closed->open", "open->half-open", "half-open->open", "open->half-open", "half-open->closed"}, transitions)
+}
+
+func TestCircuitBreakerSlowCalls(t *testing.T) {
+	ctx := context.Background()
+	breaker := NewCircuitBreaker(CircuitBreakerConfig{MinRequests: 2, SlowCall: time.Millisecond})
+	inner := &flakyStore{CacheStore: CreateLocalStore("local", LocalStoreConfig{}), delay: 5 * time.Millisecond}
+	store := Chain(inner, WithCircuitBreaker(breaker))
+
+	store.Get(ctx, "key1")
+	store.Get(ctx, "key1")
+	assert.Equal(t, CircuitOpen, breaker.State())
+}
+
+func TestCircuitBreakerWrites(t *testing.T) {
+	ctx := context.Background()
+	breaker := NewCircuitBreaker(CircuitBreakerConfig{MinRequests: 1, OpenDuration: 10 * time.Millisecond, QueueSize: 2})
+	inner := &switchStore{CacheStore: CreateLocalStore("local", LocalStoreConfig{})}
+	store := Chain(inner, WithCircuitBreaker(breaker))
+
+	for _, key := range []string{"key2", "key3", "key4"} {
+		assert.NoError(t, inner.CacheStore.Set(ctx, key, "old", time.Minute))
+	}
+
+	inner.setDown(true)
+	assert.Error(t, store.Set(ctx, "key1", "value1", time.Minute))
+	assert.Equal(t, CircuitOpen, breaker.State())
+
+	// Writes succeed while open, so the other tiers are still written to. Only QueueSize are kept.
+	assert.NoError(t, store.Set(ctx, "key2", "value2", time.Minute))
+	assert.NoError(t, store.Delete(ctx, "key3"))
+	assert.NoError(t, store.Set(ctx, "key4", "value4", time.Minute))
+
+	inner.setDown(false)
+	time.Sleep(15 * time.Millisecond)
+	_, err := store.Get(ctx, "key1")
+	assert.Error(t, err)
+	assert.Equal(t, CircuitClosed, breaker.State())
+
+	// The keys written while open are invalidated once closed rather than written
+	assert.Eventually(t, func() bool {
+		_, err := inner.CacheStore.Get(ctx, "key3")
+		return errors.Is(err, interfaces.ErrNotFound)
+	}, time.Second, time.Millisecond)
+	_, err = store.Get(ctx, "key2")
+	assert.ErrorIs(t, err, interfaces.ErrNotFound)
+
+	// Past QueueSize the outdated value is kept
+	value, err := store.Get(ctx, "key4")
+	assert.NoError(t, err)
+	assert.Equal(t, "old", value)
+}
@@ -0,0 +1,26 @@
+package stores
+
+import (
+	"encoding/json"
+)
+
+// Codec turns values into bytes and back for stores that keep raw bytes.
+type Codec interface {
+	Marshal(value any) ([]byte, error)
+	Unmarshal(data []byte) (any, error)
+}
+
+// JSONCodec encodes values as JSON, decoding into the generic map[string]interface{}/[]interface{}/float64 types.
+type JSONCodec struct{}
+
+func (JSONCodec) Marshal(value any) ([]byte, error) {
+	return json.Marshal(value)
+}
+
+func (JSONCodec) Unmarshal(data []byte) (any, error) {
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
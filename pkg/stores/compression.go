@@ -0,0 +1,221 @@
+package stores
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/snappy"
+	"github.com/klauspost/compress/zstd"
+	"github.com/reksie/tieredcache/pkg/interfaces"
+)
+
+// Compression is the algorithm used for values above the threshold. It is written as the
+// first byte of every stored value, so entries written with different settings can be read back.
+type Compression byte
+
+const (
+	// CompressionNone marks values stored as is
+	CompressionNone Compression = iota
+	CompressionZstd
+	CompressionSnappy
+	CompressionGzip
+)
+
+type CompressedStoreConfig struct {
+	// Compression defaults to zstd.
+	Compression Compression
+	// Threshold is the encoded size in bytes from which values are compressed, defaults to 1 KiB.
+	Threshold int
+	// Codec encodes values before compression, defaults to JSONCodec.
+	Codec Codec
+}
+
+type compressedStore struct {
+	store  interfaces.CacheStore
+	config CompressedStoreConfig
+
+	zstdEncoder *zstd.Encoder
+	zstdDecoder *zstd.Decoder
+}
+
+// CreateCompressedStore wraps a store so values are encoded with the codec and compressed above the threshold.
+// The wrapped store receives []byte values; stores that return them as strings, such as JSON based
+// stores returning base64, are handled on Get.
+func CreateCompressedStore(store interfaces.CacheStore, config CompressedStoreConfig) interfaces.CacheStore {
+	if config.Compression == CompressionNone {
+		config.Compression = CompressionZstd
+	}
+	if config.Threshold <= 0 {
+		config.Threshold = 1024
+	}
+	if config.Codec == nil {
+		config.Codec = JSONCodec{}
+	}
+
+	// Neither fails without options
+	encoder, _ := zstd.NewWriter(nil)
+	decoder, _ := zstd.NewReader(nil)
+
+	return &compressedStore{
+		store:       store,
+		config:      config,
+		zstdEncoder: encoder,
+		zstdDecoder: decoder,
+	}
+}
+
+func (c *compressedStore) Name() string {
+	return c.store.Name()
+}
+
+func (c *compressedStore) Set(ctx context.Context, key string, value any, ttl time.Duration) error {
+	data, err := c.config.Codec.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	envelope, err := c.compress(data)
+	if err != nil {
+		return err
+	}
+	return c.store.Set(ctx, key, envelope, ttl)
+}
+
+func (c *compressedStore) Get(ctx context.Context, key string) (any, error) {
+	value, err := c.store.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return c.decode(value)
+}
+
+func (c *compressedStore) GetWithTTL(ctx context.Context, key string) (any, time.Duration, error) {
+	value, ttl, err := getWithTTL(ctx, c.store, key)
+	if err != nil {
+		return nil, 0, err
+	}
+	decoded, err := c.decode(value)
+	if err != nil {
+		return nil, 0, err
+	}
+	return decoded, ttl, nil
+}
+
+// decode fails with interfaces.ErrCorrupt for anything that was not written by a compressed store.
+func (c *compressedStore) decode(value any) (any, error) {
+	envelope, err := envelopeBytes(value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", interfaces.ErrCorrupt, err)
+	}
+
+	data, err := c.decompress(envelope)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", interfaces.ErrCorrupt, err)
+	}
+
+	decoded, err := c.config.Codec.Unmarshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", interfaces.ErrCorrupt, err)
+	}
+	return decoded, nil
+}
+
+func (c *compressedStore) Delete(ctx context.Context, key string) error {
+	return c.store.Delete(ctx, key)
+}
+
+func (c *compressedStore) Clear(ctx context.Context) error {
+	return c.store.Clear(ctx)
+}
+
+func (c *compressedStore) Close() error {
+	c.zstdDecoder.Close()
+	return c.store.Close()
+}
+
+func (c *compressedStore) Stats(ctx context.Context) (interfaces.StoreStats, error) {
+	return storeStats(ctx, c.store)
+}
+
+func (c *compressedStore) OnEvict(fn func(key string)) {
+	onEvict(c.store, fn)
+}
+
+// compress prefixes data with its compression flag, values below the threshold or that do not shrink are kept as is.
+func (c *compressedStore) compress(data []byte) ([]byte, error) {
+	if len(data) < c.config.Threshold {
+		return append([]byte{byte(CompressionNone)}, data...), nil
+	}
+
+	compressed := []byte{byte(c.config.Compression)}
+	switch c.config.Compression {
+	case CompressionZstd:
+		compressed = c.zstdEncoder.EncodeAll(data, compressed)
+	case CompressionSnappy:
+		compressed = append(compressed, snappy.Encode(nil, data)...)
+	case CompressionGzip:
+		buf := bytes.NewBuffer(compressed)
+		writer := gzip.NewWriter(buf)
+		if _, err := writer.Write(data); err != nil {
+			return nil, err
+		}
+		if err := writer.Close(); err != nil {
+			return nil, err
+		}
+		compressed = buf.Bytes()
+	default:
+		return nil, fmt.Errorf("unknown compression %d", c.config.Compression)
+	}
+
+	if len(compressed) >= len(data)+1 {
+		return append([]byte{byte(CompressionNone)}, data...), nil
+	}
+	return compressed, nil
+}
+
+func (c *compressedStore) decompress(envelope []byte) ([]byte, error) {
+	if len(envelope) == 0 {
+		return nil, fmt.Errorf("empty compressed value")
+	}
+
+	data := envelope[1:]
+	switch Compression(envelope[0]) {
+	case CompressionNone:
+		return data, nil
+	case CompressionZstd:
+		return c.zstdDecoder.DecodeAll(data, nil)
+	case CompressionSnappy:
+		return snappy.Decode(nil, data)
+	case CompressionGzip:
+		reader, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer reader.Close()
+		return io.ReadAll(reader)
+	default:
+		return nil, fmt.Errorf("unknown compression flag %d", envelope[0])
+	}
+}
+
+// envelopeBytes gets the bytes written by a wrapping store back. Stores keeping values in memory return
+// the []byte, raw Redis returns a string of the bytes, and JSON based stores return a base64 string.
+// Base64 never starts with a byte below 0x20, which every envelope does, so the two string forms can be told apart.
+func envelopeBytes(value any) ([]byte, error) {
+	switch v := value.(type) {
+	case []byte:
+		return v, nil
+	case string:
+		if len(v) > 0 && v[0] < 0x20 {
+			return []byte(v), nil
+		}
+		return base64.StdEncoding.DecodeString(v)
+	default:
+		return nil, fmt.Errorf("unexpected stored value of type %T", value)
+	}
+}
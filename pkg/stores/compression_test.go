@@ -0,0 +1,101 @@
+package stores
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/reksie/tieredcache/pkg/interfaces"
+	"github.com/stretchr/testify/assert"
+)
+
+func largeTestDocument() map[string]any {
+	return map[string]any{
+		"name": "John",
+		"bio":  strings.Repeat("lorem ipsum dolor sit amet ", 200),
+	}
+}
+
+func TestCompressedStoreAlgorithms(t *testing.T) {
+	ctx := context.Background()
+	document := largeTestDocument()
+
+	for _, compression := range []Compression{CompressionZstd, CompressionSnappy, CompressionGzip} {
+		inner := CreateLocalStore("local", LocalStoreConfig{})
+		store := CreateCompressedStore(inner, CompressedStoreConfig{Compression: compression})
+		assert.Equal(t, "local", store.Name())
+
+		assert.NoError(t, store.Set(ctx, "doc", document, time.Minute))
+
+		raw, err := inner.Get(ctx, "doc")
+		assert.NoError(t, err)
+		assert.Equal(t, byte(compression), raw.([]byte)[0])
+		assert.Less(t, len(raw.([]byte)), len(document["bio"].(string)))
+
+		value, err := store.Get(ctx, "doc")
+		assert.NoError(t, err)
+		assert.Equal(t, document, value)
+	}
+}
+
+func TestCompressedStoreThreshold(t *testing.T) {
+	ctx := context.Background()
+	inner := CreateLocalStore("local", LocalStoreConfig{})
+	store := CreateCompressedStore(inner, CompressedStoreConfig{Threshold: 100})
+
+	assert.NoError(t, store.Set(ctx, "small", "value1", time.Minute))
+	raw, err := inner.Get(ctx, "small")
+	assert.NoError(t, err)
+	assert.Equal(t, append([]byte{byte(CompressionNone)}, `"value1"`...), raw)
+
+	// Entries written with other settings are still readable, the flag byte says how to read them
+	assert.NoError(t, store.Set(ctx, "large", largeTestDocument(), time.Minute))
+	other := CreateCompressedStore(inner, CompressedStoreConfig{Compression: CompressionGzip})
+	for _, key := range []string{"small", "large"} {
+		_, err := other.Get(ctx, key)
+		assert.NoError(t, err)
+	}
+}
+
+func TestCompressedStoreStringBackends(t *testing.T) {
+	ctx := context.Background()
+	cache, err := createTestCache()
+	assert.NoError(t, err)
+
+	backends := map[string]interfaces.CacheStore{
+		// bigcache returns the bytes as a base64 string after its JSON round trip
+		"bigcache": CreateMemoryStore("memory", cache),
+		// Redis without JSON marshalling returns the raw bytes as a string
+		"redis": CreateRedisStore("redis", redisClient, RedisStoreConfig{}),
+	}
+
+	for name, backend := range backends {
+		store := CreateCompressedStore(backend, CompressedStoreConfig{Threshold: 100})
+		for _, value := range []any{"value1", largeTestDocument()} {
+			assert.NoError(t, store.Set(ctx, "compressed:"+name, value, time.Minute))
+			got, err := store.Get(ctx, "compressed:"+name)
+			assert.NoError(t, err, name)
+			assert.Equal(t, value, got, name)
+		}
+	}
+}
+
+func TestCompressedStoreUnknownFlag(t *testing.T) {
+	ctx := context.Background()
+	inner := CreateLocalStore("local", LocalStoreConfig{})
+	store := CreateCompressedStore(inner, CompressedStoreConfig{})
+
+	assert.NoError(t, inner.Set(ctx, "key1", []byte{0x1f, 'x'}, time.Minute))
+	_, err := store.Get(ctx, "key1")
+	assert.ErrorIs(t, err, interfaces.ErrCorrupt)
+
+	// A truncated payload fails to decompress
+	assert.NoError(t, inner.Set(ctx, "key1", []byte{byte(CompressionZstd), 0x28, 0xb5}, time.Minute))
+	_, err = store.Get(ctx, "key1")
+	assert.ErrorIs(t, err, interfaces.ErrCorrupt)
+
+	assert.NoError(t, inner.Set(ctx, "key1", 42, time.Minute))
+	_, err = store.Get(ctx, "key1")
+	assert.ErrorIs(t, err, interfaces.ErrCorrupt)
+}
@@ -0,0 +1,452 @@
+package stores
+
+import (
+	"container/list"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/reksie/tieredcache/pkg/interfaces"
+	"github.com/zeebo/xxh3"
+)
+
+const (
+	diskEntryExtension = ".entry"
+	diskTempPrefix     = ".tmp-"
+	// entries start with the expiry as unix nanoseconds, so the sweeper and the index
+	// can read it without decoding the value
+	diskHeaderSize = 8
+)
+
+type DiskStoreConfig struct {
+	// MaxBytes is the size budget of the directory, least recently used entries are evicted above it. 0 means unbounded.
+	MaxBytes int64
+	// SweepInterval periodically removes expired entries, 0 disables the sweeper.
+	SweepInterval time.Duration
+	// Sync fsyncs entries before they become visible, and their directory once they are renamed into place,
+	// so they survive a machine crash and not only a process restart.
+	Sync bool
+}
+
+type diskStore struct {
+	name   string
+	dir    string
+	config DiskStoreConfig
+
+	mu    sync.Mutex
+	index map[string]*diskIndexEntry
+	lru   *list.List
+	bytes int64
+
+	stop chan struct{}
+	once sync.Once
+}
+
+type diskIndexEntry struct {
+	file      string
+	size      int64
+	expiresAt time.Time
+	element   *list.Element
+}
+
+type diskItem struct {
+	Key   string `json:"key"`
+	Value any    `json:"value"`
+}
+
+// CreateDiskStore creates a store that persists entries as files under dir, so they survive restarts.
+// Writes go to a temporary file that is renamed into place, so a crash never leaves a partial entry behind.
+func CreateDiskStore(name string, dir string, config DiskStoreConfig) (interfaces.CacheStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	store := &diskStore{
+		name:   name,
+		dir:    dir,
+		config: config,
+		index:  make(map[string]*diskIndexEntry),
+		lru:    list.New(),
+		stop:   make(chan struct{}),
+	}
+
+	if err := store.load(); err != nil {
+		return nil, err
+	}
+
+	if config.SweepInterval > 0 {
+		go store.sweep(config.SweepInterval)
+	}
+
+	return store, nil
+}
+
+// load rebuilds the index from the directory, oldest modified entries first in the eviction order.
+func (d *diskStore) load() error {
+	type loaded struct {
+		entry   *diskIndexEntry
+		modTime time.Time
+	}
+	var entries []loaded
+
+	err := filepath.WalkDir(d.dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			// Other directories are not the store's, dir may be shared
+			if path != d.dir && !isDiskShard(entry.Name()) {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		// Left over from a write that crashed before being renamed into place
+		if strings.HasPrefix(entry.Name(), diskTempPrefix) {
+			return os.Remove(path)
+		}
+		if !strings.HasSuffix(entry.Name(), diskEntryExtension) {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		expiresAt, err := readDiskExpiry(path)
+		if err != nil {
+			// Unreadable entries are dropped rather than failing the whole store
+			return os.Remove(path)
+		}
+
+		entries = append(entries, loaded{
+			entry:   &diskIndexEntry{file: path, size: info.Size(), expiresAt: expiresAt},
+			modTime: info.ModTime(),
+		})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].modTime.Before(entries[j].modTime)
+	})
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, l := range entries {
+		l.entry.element = d.lru.PushFront(l.entry)
+		d.index[l.entry.file] = l.entry
+		d.bytes += l.entry.size
+	}
+	d.evict()
+	return nil
+}
+
+func readDiskExpiry(path string) (time.Time, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer file.Close()
+
+	var header [diskHeaderSize]byte
+	if _, err := io.ReadFull(file, header[:]); err != nil {
+		return time.Time{}, err
+	}
+	return diskExpiry(header[:]), nil
+}
+
+func diskExpiry(header []byte) time.Time {
+	nanos := int64(binary.BigEndian.Uint64(header))
+	if nanos == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nanos)
+}
+
+// path maps a key to a file, keys are hashed so any key is a valid file name
+func (d *diskStore) path(key string) string {
+	sum := xxh3.HashString128(key).Bytes()
+	name := hex.EncodeToString(sum[:])
+	return filepath.Join(d.dir, name[:2], name+diskEntryExtension)
+}
+
+func (d *diskStore) Name() string {
+	return d.name
+}
+
+func (d *diskStore) Set(ctx context.Context, key string, value any, ttl time.Duration) error {
+	data, err := json.Marshal(diskItem{Key: key, Value: value})
+	if err != nil {
+		return err
+	}
+
+	var expiresAt time.Time
+	header := make([]byte, diskHeaderSize, diskHeaderSize+len(data))
+	if ttl > 0 {
+		expiresAt = time.Now().Add(ttl)
+		binary.BigEndian.PutUint64(header, uint64(expiresAt.UnixNano()))
+	}
+	data = append(header, data...)
+
+	path := d.path(key)
+	dir := filepath.Dir(path)
+	// A concurrent Clear may remove the temporary file or its directory, it then wins as if it ran after the write
+	tempPath, err := d.writeTemp(dir, data)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	// Renamed under the lock, so a concurrent Delete or Clear never leaves a file the index does not know about
+	d.mu.Lock()
+	if err := os.Rename(tempPath, path); err != nil {
+		d.mu.Unlock()
+		os.Remove(tempPath)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	d.removeIndex(path)
+	entry := &diskIndexEntry{file: path, size: int64(len(data)), expiresAt: expiresAt}
+	entry.element = d.lru.PushFront(entry)
+	d.index[path] = entry
+	d.bytes += entry.size
+	d.evict()
+	d.mu.Unlock()
+
+	if d.config.Sync {
+		// The rename is only durable once the directory holding it is
+		return syncDir(dir)
+	}
+	return nil
+}
+
+// writeTemp writes data to a temporary file in dir, to be renamed into place once complete.
+func (d *diskStore) writeTemp(dir string, data []byte) (string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+
+	file, err := os.CreateTemp(dir, diskTempPrefix+"*")
+	if err != nil {
+		return "", err
+	}
+	tempPath := file.Name()
+
+	_, err = file.Write(data)
+	if err == nil && d.config.Sync {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tempPath)
+		return "", err
+	}
+	return tempPath, nil
+}
+
+func syncDir(dir string) error {
+	file, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	err = file.Sync()
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (d *diskStore) Get(ctx context.Context, key string) (any, error) {
+	value, _, err := d.GetWithTTL(ctx, key)
+	return value, err
+}
+
+// GetWithTTL reads the remaining TTL from the entry header, so hits on the disk tier are promoted with it.
+func (d *diskStore) GetWithTTL(ctx context.Context, key string) (any, time.Duration, error) {
+	path := d.path(key)
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		d.mu.Lock()
+		d.removeIndex(path)
+		d.mu.Unlock()
+		return nil, 0, interfaces.ErrNotFound
+	} else if err != nil {
+		return nil, 0, err
+	}
+
+	if len(data) < diskHeaderSize {
+		d.Delete(ctx, key)
+		return nil, 0, fmt.Errorf("%w: truncated disk entry", interfaces.ErrCorrupt)
+	}
+
+	var remaining time.Duration
+	if expiresAt := diskExpiry(data[:diskHeaderSize]); !expiresAt.IsZero() {
+		remaining = time.Until(expiresAt)
+		if remaining <= 0 {
+			d.Delete(ctx, key)
+			return nil, 0, interfaces.ErrExpired
+		}
+	}
+
+	var item diskItem
+	if err := json.Unmarshal(data[diskHeaderSize:], &item); err != nil {
+		return nil, 0, fmt.Errorf("%w: %v", interfaces.ErrCorrupt, err)
+	}
+	if item.Key != key {
+		// Hash collision with another key
+		return nil, 0, interfaces.ErrNotFound
+	}
+
+	d.mu.Lock()
+	if entry, found := d.index[path]; found {
+		d.lru.MoveToFront(entry.element)
+	}
+	d.mu.Unlock()
+
+	return item.Value, remaining, nil
+}
+
+func (d *diskStore) Delete(ctx context.Context, key string) error {
+	path := d.path(key)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.removeIndex(path)
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (d *diskStore) Clear(ctx context.Context) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// Only what the store wrote is removed, dir may be shared with other files
+	entries, err := os.ReadDir(d.dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		path := filepath.Join(d.dir, entry.Name())
+		if !entry.IsDir() {
+			if err := removeDiskFile(path); err != nil {
+				return err
+			}
+			continue
+		}
+		if !isDiskShard(entry.Name()) {
+			continue
+		}
+
+		files, err := os.ReadDir(path)
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			if err := removeDiskFile(filepath.Join(path, file.Name())); err != nil {
+				return err
+			}
+		}
+		// Left in place when something else is in it, such as a write in progress
+		os.Remove(path)
+	}
+
+	d.index = make(map[string]*diskIndexEntry)
+	d.lru.Init()
+	d.bytes = 0
+	return nil
+}
+
+func (d *diskStore) Close() error {
+	d.once.Do(func() {
+		close(d.stop)
+	})
+	return nil
+}
+
+func (d *diskStore) sweep(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-d.stop:
+			return
+		case now := <-ticker.C:
+			d.mu.Lock()
+			for path, entry := range d.index {
+				if !entry.expiresAt.IsZero() && now.After(entry.expiresAt) {
+					d.removeIndex(path)
+					os.Remove(path)
+				}
+			}
+			d.mu.Unlock()
+		}
+	}
+}
+
+// evict removes least recently used entries until the store is within its budget, d.mu must be held.
+func (d *diskStore) evict() {
+	if d.config.MaxBytes <= 0 {
+		return
+	}
+	for d.bytes > d.config.MaxBytes && d.lru.Len() > 0 {
+		entry := d.lru.Back().Value.(*diskIndexEntry)
+		d.removeIndex(entry.file)
+		os.Remove(entry.file)
+	}
+}
+
+// removeDiskFile removes an entry or temporary file, leaving other files alone.
+func removeDiskFile(path string) error {
+	name := filepath.Base(path)
+	if !strings.HasSuffix(name, diskEntryExtension) && !strings.HasPrefix(name, diskTempPrefix) {
+		return nil
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// isDiskShard tells whether a directory is one of the two hex digit directories entries are spread over.
+func isDiskShard(name string) bool {
+	if len(name) != 2 {
+		return false
+	}
+	_, err := hex.DecodeString(name)
+	return err == nil && strings.ToLower(name) == name
+}
+
+// removeIndex forgets an entry without touching the file, d.mu must be held.
+func (d *diskStore) removeIndex(path string) {
+	entry, found := d.index[path]
+	if !found {
+		return
+	}
+	d.lru.Remove(entry.element)
+	delete(d.index, path)
+	d.bytes -= entry.size
+}
@@ -0,0 +1,243 @@
+package stores
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/reksie/tieredcache/pkg/interfaces"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiskSetGet(t *testing.T) {
+	ctx := context.Background()
+	store, err := CreateDiskStore("disk", t.TempDir(), DiskStoreConfig{})
+	assert.NoError(t, err)
+	defer store.Close()
+	assert.Equal(t, "disk", store.Name())
+
+	assert.NoError(t, store.Set(ctx, "key1", map[string]any{"name": "John"}, time.Minute))
+
+	value, err := store.Get(ctx, "key1")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"name": "John"}, value)
+
+	_, err = store.Get(ctx, "non_existent_key")
+	assert.Error(t, err)
+}
+
+func TestDiskSurvivesRestart(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	store, err := CreateDiskStore("disk", dir, DiskStoreConfig{Sync: true})
+	assert.NoError(t, err)
+	assert.NoError(t, store.Set(ctx, "key1", "value1", time.Minute))
+	assert.NoError(t, store.Close())
+
+	// A crashed write leaves a temporary file behind
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, diskTempPrefix+"crashed"), []byte("partial"), 0o644))
+
+	reopened, err := CreateDiskStore("disk", dir, DiskStoreConfig{})
+	assert.NoError(t, err)
+	defer reopened.Close()
+	value, err := reopened.Get(ctx, "key1")
+	assert.NoError(t, err)
+	assert.Equal(t, "value1", value)
+
+	_, err = os.Stat(filepath.Join(dir, diskTempPrefix+"crashed"))
+	assert.True(t, os.IsNotExist(err), "temporary files should be removed on load")
+}
+
+func TestDiskGetExpired(t *testing.T) {
+	ctx := context.Background()
+	store, err := CreateDiskStore("disk", t.TempDir(), DiskStoreConfig{})
+	assert.NoError(t, err)
+	defer store.Close()
+
+	assert.NoError(t, store.Set(ctx, "key1", "value1", 5*time.Millisecond))
+	time.Sleep(10 * time.Millisecond)
+
+	_, err = store.Get(ctx, "key1")
+	assert.Error(t, err)
+}
+
+func TestDiskGetWithTTL(t *testing.T) {
+	ctx := context.Background()
+	store, err := CreateDiskStore("disk", t.TempDir(), DiskStoreConfig{})
+	assert.NoError(t, err)
+	defer store.Close()
+	ttlStore := store.(interfaces.TTLStore)
+
+	assert.NoError(t, store.Set(ctx, "key1", "value1", time.Minute))
+	value, ttl, err := ttlStore.GetWithTTL(ctx, "key1")
+	assert.NoError(t, err)
+	assert.Equal(t, "value1", value)
+	assert.InDelta(t, time.Minute, ttl, float64(time.Second))
+
+	// Entries without a TTL do not expire
+	assert.NoError(t, store.Set(ctx, "key2", "value2", 0))
+	_, ttl, err = ttlStore.GetWithTTL(ctx, "key2")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(0), ttl)
+}
+
+func TestDiskSweeper(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store, err := CreateDiskStore("disk", dir, DiskStoreConfig{SweepInterval: 5 * time.Millisecond})
+	assert.NoError(t, err)
+	defer store.Close()
+
+	assert.NoError(t, store.Set(ctx, "key1", "value1", 5*time.Millisecond))
+	time.Sleep(30 * time.Millisecond)
+
+	assert.Empty(t, diskEntryFiles(t, dir))
+}
+
+func TestDiskEviction(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	// Each entry is a little over 30 bytes, so only two fit
+	store, err := CreateDiskStore("disk", dir, DiskStoreConfig{MaxBytes: 80})
+	assert.NoError(t, err)
+	defer store.Close()
+	assert.NoError(t, store.Set(ctx, "a", "1", time.Minute))
+	assert.NoError(t, store.Set(ctx, "b", "2", time.Minute))
+	_, err = store.Get(ctx, "a")
+	assert.NoError(t, err)
+	assert.NoError(t, store.Set(ctx, "c", "3", time.Minute))
+
+	_, err = store.Get(ctx, "b")
+	assert.Error(t, err, "least recently used entry should be evicted")
+	_, err = store.Get(ctx, "a")
+	assert.NoError(t, err)
+	_, err = store.Get(ctx, "c")
+	assert.NoError(t, err)
+	assert.Len(t, diskEntryFiles(t, dir), 2)
+}
+
+func TestDiskDeleteAndClear(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store, err := CreateDiskStore("disk", dir, DiskStoreConfig{})
+	assert.NoError(t, err)
+	defer store.Close()
+
+	assert.NoError(t, store.Set(ctx, "key1", "value1", time.Minute))
+	assert.NoError(t, store.Set(ctx, "key2", "value2", time.Minute))
+
+	assert.NoError(t, store.Delete(ctx, "key1"))
+	_, err = store.Get(ctx, "key1")
+	assert.Error(t, err)
+
+	assert.NoError(t, store.Clear(ctx))
+	_, err = store.Get(ctx, "key2")
+	assert.Error(t, err)
+	assert.Empty(t, diskEntryFiles(t, dir))
+}
+
+func TestDiskConcurrentSetAndClear(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store, err := CreateDiskStore("disk", dir, DiskStoreConfig{})
+	assert.NoError(t, err)
+	defer store.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				key := fmt.Sprintf("key%d-%d", i, j)
+				assert.NoError(t, store.Set(ctx, key, "value", time.Minute))
+				if j%5 == 0 {
+					assert.NoError(t, store.Clear(ctx))
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// Every file left behind is in the index, so it is accounted for and evicted
+	disk := store.(*diskStore)
+	var files []string
+	filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err == nil && strings.HasSuffix(path, diskEntryExtension) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	indexed := make([]string, 0, len(disk.index))
+	for path := range disk.index {
+		indexed = append(indexed, path)
+	}
+	assert.ElementsMatch(t, files, indexed)
+}
+
+func TestDiskClearKeepsOtherFiles(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store, err := CreateDiskStore("disk", dir, DiskStoreConfig{})
+	assert.NoError(t, err)
+	defer store.Close()
+	assert.NoError(t, store.Set(ctx, "key1", "value1", time.Minute))
+
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("keep"), 0o644))
+	assert.NoError(t, os.Mkdir(filepath.Join(dir, "data"), 0o755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "data", "a.entry"), []byte("keep"), 0o644))
+	shard := filepath.Dir(diskEntryFiles(t, dir)[0])
+	assert.NoError(t, os.WriteFile(filepath.Join(shard, "README"), []byte("keep"), 0o644))
+
+	assert.NoError(t, store.Clear(ctx))
+	assert.Equal(t, []string{filepath.Join(dir, "data", "a.entry")}, diskEntryFiles(t, dir))
+	for _, path := range []string{filepath.Join(dir, "notes.txt"), filepath.Join(shard, "README")} {
+		_, err := os.Stat(path)
+		assert.NoError(t, err)
+	}
+
+	// Nor are they touched when the directory is loaded
+	reopened, err := CreateDiskStore("disk", dir, DiskStoreConfig{})
+	assert.NoError(t, err)
+	defer reopened.Close()
+	assert.NoError(t, reopened.Clear(ctx))
+	assert.Len(t, diskEntryFiles(t, dir), 1)
+}
+
+func TestDiskCorruptEntry(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store, err := CreateDiskStore("disk", dir, DiskStoreConfig{})
+	assert.NoError(t, err)
+	defer store.Close()
+
+	assert.NoError(t, store.Set(ctx, "key1", "value1", time.Minute))
+	file := diskEntryFiles(t, dir)[0]
+	assert.NoError(t, os.WriteFile(file, []byte("\x00\x00\x00\x00\x00\x00\x00\x00{truncated"), 0o644))
+	_, err = store.Get(ctx, "key1")
+	assert.ErrorIs(t, err, interfaces.ErrCorrupt)
+
+	assert.NoError(t, os.WriteFile(file, []byte("abc"), 0o644))
+	_, err = store.Get(ctx, "key1")
+	assert.ErrorIs(t, err, interfaces.ErrCorrupt)
+}
+
+func diskEntryFiles(t *testing.T, dir string) []string {
+	var files []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && strings.HasSuffix(path, diskEntryExtension) {
+			files = append(files, path)
+		}
+		return err
+	})
+	assert.NoError(t, err)
+	return files
+}
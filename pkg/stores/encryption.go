@@ -0,0 +1,188 @@
+package stores
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+	"time"
+
+	"github.com/reksie/tieredcache/pkg/interfaces"
+)
+
+// encryptionVersion is the first byte of every envelope, below 0x20 like the compression flags
+const encryptionVersion byte = 1
+
+type EncryptedStoreConfig struct {
+	// Keys maps key IDs to AES keys of 16, 24 or 32 bytes. Every key can decrypt,
+	// so keys that were rotated out should stay until their entries have expired.
+	Keys map[string][]byte
+	// CurrentKeyID is the key new values are encrypted with.
+	CurrentKeyID string
+	// Codec encodes values before encryption, defaults to JSONCodec.
+	Codec Codec
+}
+
+type encryptedStore struct {
+	store     interfaces.CacheStore
+	codec     Codec
+	currentID string
+	aeads     map[string]cipher.AEAD
+}
+
+// CreateEncryptedStore wraps a store so values are encrypted with AES-GCM before they reach it.
+//
+// The envelope is the version byte, the key ID length and key ID, the nonce and the ciphertext.
+// The version, key ID and cache key are authenticated as additional data, so an entry copied to
+// another cache key or relabelled with another key ID fails to decrypt.
+func CreateEncryptedStore(store interfaces.CacheStore, config EncryptedStoreConfig) (interfaces.CacheStore, error) {
+	if _, ok := config.Keys[config.CurrentKeyID]; !ok {
+		return nil, fmt.Errorf("current key %q is not in the keys", config.CurrentKeyID)
+	}
+	if config.Codec == nil {
+		config.Codec = JSONCodec{}
+	}
+
+	aeads := make(map[string]cipher.AEAD, len(config.Keys))
+	for id, key := range config.Keys {
+		if len(id) > 255 {
+			return nil, fmt.Errorf("key ID %q is longer than 255 bytes", id)
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", id, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", id, err)
+		}
+		aeads[id] = aead
+	}
+
+	return &encryptedStore{
+		store:     store,
+		codec:     config.Codec,
+		currentID: config.CurrentKeyID,
+		aeads:     aeads,
+	}, nil
+}
+
+func (e *encryptedStore) Name() string {
+	return e.store.Name()
+}
+
+func (e *encryptedStore) Set(ctx context.Context, key string, value any, ttl time.Duration) error {
+	data, err := e.codec.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	aead := e.aeads[e.currentID]
+	header := append([]byte{encryptionVersion, byte(len(e.currentID))}, e.currentID...)
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+
+	envelope := append(header, nonce...)
+	envelope = aead.Seal(envelope, nonce, data, encryptionAAD(header, key))
+	return e.store.Set(ctx, key, envelope, ttl)
+}
+
+func (e *encryptedStore) Get(ctx context.Context, key string) (any, error) {
+	value, err := e.store.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return e.decode(key, value)
+}
+
+func (e *encryptedStore) GetWithTTL(ctx context.Context, key string) (any, time.Duration, error) {
+	value, ttl, err := getWithTTL(ctx, e.store, key)
+	if err != nil {
+		return nil, 0, err
+	}
+	decoded, err := e.decode(key, value)
+	if err != nil {
+		return nil, 0, err
+	}
+	return decoded, ttl, nil
+}
+
+func (e *encryptedStore) decode(key string, value any) (any, error) {
+	envelope, err := envelopeBytes(value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", interfaces.ErrCorrupt, err)
+	}
+
+	data, err := e.open(key, envelope)
+	if err != nil {
+		return nil, err
+	}
+
+	decoded, err := e.codec.Unmarshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", interfaces.ErrCorrupt, err)
+	}
+	return decoded, nil
+}
+
+func (e *encryptedStore) Delete(ctx context.Context, key string) error {
+	return e.store.Delete(ctx, key)
+}
+
+func (e *encryptedStore) Clear(ctx context.Context) error {
+	return e.store.Clear(ctx)
+}
+
+func (e *encryptedStore) Close() error {
+	return e.store.Close()
+}
+
+func (e *encryptedStore) Stats(ctx context.Context) (interfaces.StoreStats, error) {
+	return storeStats(ctx, e.store)
+}
+
+func (e *encryptedStore) OnEvict(fn func(key string)) {
+	onEvict(e.store, fn)
+}
+
+// open decrypts an envelope, failing with interfaces.ErrCorrupt when it is malformed, was written with a key
+// that is no longer configured, or does not authenticate.
+func (e *encryptedStore) open(key string, envelope []byte) ([]byte, error) {
+	if len(envelope) < 2 || envelope[0] != encryptionVersion {
+		return nil, fmt.Errorf("%w: invalid encrypted value", interfaces.ErrCorrupt)
+	}
+
+	headerLength := 2 + int(envelope[1])
+	if len(envelope) < headerLength {
+		return nil, fmt.Errorf("%w: invalid encrypted value", interfaces.ErrCorrupt)
+	}
+	header := envelope[:headerLength]
+	keyID := string(header[2:])
+
+	aead, ok := e.aeads[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown encryption key %q", interfaces.ErrCorrupt, keyID)
+	}
+
+	rest := envelope[headerLength:]
+	if len(rest) < aead.NonceSize() {
+		return nil, fmt.Errorf("%w: invalid encrypted value", interfaces.ErrCorrupt)
+	}
+	nonce, ciphertext := rest[:aead.NonceSize()], rest[aead.NonceSize():]
+	data, err := aead.Open(nil, nonce, ciphertext, encryptionAAD(header, key))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", interfaces.ErrCorrupt, err)
+	}
+	return data, nil
+}
+
+// encryptionAAD binds a ciphertext to its envelope header and cache key.
+func encryptionAAD(header []byte, key string) []byte {
+	aad := make([]byte, 0, len(header)+len(key))
+	aad = append(aad, header...)
+	return append(aad, key...)
+}
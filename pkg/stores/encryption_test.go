@@ -0,0 +1,149 @@
+package stores
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/reksie/tieredcache/pkg/interfaces"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	testKeyV1 = bytes.Repeat([]byte{1}, 32)
+	testKeyV2 = bytes.Repeat([]byte{2}, 32)
+)
+
+func TestEncryptedStoreSetGet(t *testing.T) {
+	ctx := context.Background()
+	inner := CreateLocalStore("local", LocalStoreConfig{})
+	defer inner.Close()
+	store, err := CreateEncryptedStore(inner, EncryptedStoreConfig{
+		Keys:         map[string][]byte{"v1": testKeyV1},
+		CurrentKeyID: "v1",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "local", store.Name())
+
+	assert.NoError(t, store.Set(ctx, "key1", map[string]any{"email": "john@example.com"}, time.Minute))
+
+	raw, err := inner.Get(ctx, "key1")
+	assert.NoError(t, err)
+	assert.NotContains(t, string(raw.([]byte)), "john@example.com")
+	assert.Equal(t, []byte{encryptionVersion, 2, 'v', '1'}, raw.([]byte)[:4])
+
+	value, err := store.Get(ctx, "key1")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"email": "john@example.com"}, value)
+}
+
+func TestEncryptedStoreKeyRotation(t *testing.T) {
+	ctx := context.Background()
+	inner := CreateLocalStore("local", LocalStoreConfig{})
+	defer inner.Close()
+	old, err := CreateEncryptedStore(inner, EncryptedStoreConfig{
+		Keys:         map[string][]byte{"v1": testKeyV1},
+		CurrentKeyID: "v1",
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, old.Set(ctx, "old", "value1", time.Minute))
+
+	rotated, err := CreateEncryptedStore(inner, EncryptedStoreConfig{
+		Keys:         map[string][]byte{"v1": testKeyV1, "v2": testKeyV2},
+		CurrentKeyID: "v2",
+	})
+	assert.NoError(t, err)
+
+	// Old entries are still readable, new ones use the current key
+	value, err := rotated.Get(ctx, "old")
+	assert.NoError(t, err)
+	assert.Equal(t, "value1", value)
+
+	assert.NoError(t, rotated.Set(ctx, "new", "value2", time.Minute))
+	raw, err := inner.Get(ctx, "new")
+	assert.NoError(t, err)
+	assert.Equal(t, "v2", string(raw.([]byte)[2:4]))
+
+	// Once the old key is removed its entries can no longer be read, and are treated as corrupt
+	retired, err := CreateEncryptedStore(inner, EncryptedStoreConfig{
+		Keys:         map[string][]byte{"v2": testKeyV2},
+		CurrentKeyID: "v2",
+	})
+	assert.NoError(t, err)
+	_, err = retired.Get(ctx, "old")
+	assert.ErrorIs(t, err, interfaces.ErrCorrupt)
+	_, err = retired.Get(ctx, "new")
+	assert.NoError(t, err)
+}
+
+func TestEncryptedStoreKeyBinding(t *testing.T) {
+	ctx := context.Background()
+	inner := CreateLocalStore("local", LocalStoreConfig{})
+	defer inner.Close()
+	store, err := CreateEncryptedStore(inner, EncryptedStoreConfig{
+		Keys:         map[string][]byte{"v1": testKeyV1, "v2": testKeyV1},
+		CurrentKeyID: "v1",
+	})
+	assert.NoError(t, err)
+
+	assert.NoError(t, store.Set(ctx, "user:1", "secret", time.Minute))
+	raw, err := inner.Get(ctx, "user:1")
+	assert.NoError(t, err)
+
+	// Copied to another cache key
+	assert.NoError(t, inner.Set(ctx, "user:2", raw, time.Minute))
+	_, err = store.Get(ctx, "user:2")
+	assert.ErrorIs(t, err, interfaces.ErrCorrupt)
+
+	// Relabelled with another key ID, even one with the same key material
+	relabelled := bytes.Clone(raw.([]byte))
+	relabelled[3] = '2'
+	assert.NoError(t, inner.Set(ctx, "user:1", relabelled, time.Minute))
+	_, err = store.Get(ctx, "user:1")
+	assert.ErrorIs(t, err, interfaces.ErrCorrupt)
+
+	// Truncated
+	assert.NoError(t, inner.Set(ctx, "user:1", raw.([]byte)[:10], time.Minute))
+	_, err = store.Get(ctx, "user:1")
+	assert.ErrorIs(t, err, interfaces.ErrCorrupt)
+
+	// Not an envelope at all
+	assert.NoError(t, inner.Set(ctx, "user:1", []byte{0x1f, 'x'}, time.Minute))
+	_, err = store.Get(ctx, "user:1")
+	assert.ErrorIs(t, err, interfaces.ErrCorrupt)
+}
+
+func TestEncryptedStoreThroughJSONStore(t *testing.T) {
+	ctx := context.Background()
+	cache, err := createTestCache()
+	assert.NoError(t, err)
+
+	store, err := CreateEncryptedStore(CreateMemoryStore("memory", cache), EncryptedStoreConfig{
+		Keys:         map[string][]byte{"v1": testKeyV1},
+		CurrentKeyID: "v1",
+	})
+	assert.NoError(t, err)
+
+	assert.NoError(t, store.Set(ctx, "key1", "value1", time.Minute))
+	value, err := store.Get(ctx, "key1")
+	assert.NoError(t, err)
+	assert.Equal(t, "value1", value)
+}
+
+func TestEncryptedStoreInvalidConfig(t *testing.T) {
+	inner := CreateLocalStore("local", LocalStoreConfig{})
+	defer inner.Close()
+
+	_, err := CreateEncryptedStore(inner, EncryptedStoreConfig{
+		Keys:         map[string][]byte{"v1": testKeyV1},
+		CurrentKeyID: "v2",
+	})
+	assert.Error(t, err)
+
+	_, err = CreateEncryptedStore(inner, EncryptedStoreConfig{
+		Keys:         map[string][]byte{"v1": []byte("short")},
+		CurrentKeyID: "v1",
+	})
+	assert.Error(t, err)
+}
@@ -0,0 +1,463 @@
+package stores
+
+import (
+	"container/heap"
+	"container/list"
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/reksie/tieredcache/pkg/interfaces"
+)
+
+// EvictionPolicy decides which entry a bounded local store removes to make room.
+type EvictionPolicy int
+
+const (
+	// EvictLRU removes the least recently used entry.
+	EvictLRU EvictionPolicy = iota
+	// EvictLFU removes the least frequently used entry, oldest first among equals.
+	EvictLFU
+)
+
+type LocalStoreConfig struct {
+	// Shards is the number of independently locked shards, rounded up to a power of two. Defaults to 16.
+	Shards int
+	// MaxEntries bounds the number of entries of the whole store, 0 means unbounded.
+	MaxEntries int
+	// MaxBytes bounds the total cost of the entries of the whole store, 0 means unbounded.
+	// Writes to a bounded store are serialized to keep the bounds exact, reads stay sharded.
+	MaxBytes int64
+	// Cost returns the size of a value in bytes, only used when MaxBytes is set. Defaults to EncodedSizeCost.
+	Cost func(value any) int64
+	// Eviction chooses the entry to remove when a bound is reached.
+	Eviction EvictionPolicy
+	// CleanupInterval periodically removes expired entries. Expired entries are always
+	// removed lazily on Get, so this is only needed to release memory of keys that are never read again.
+	CleanupInterval time.Duration
+}
+
+type localStore struct {
+	name   string
+	config LocalStoreConfig
+	shards []*localShard
+	mask   uint64
+	stop   chan struct{}
+	once   sync.Once
+
+	// writeMu serializes the writes of bounded stores, so making room and adding an entry is atomic
+	writeMu sync.Mutex
+	// ticks orders uses across shards, so victims from different shards can be compared
+	ticks atomic.Uint64
+	count localCount
+
+	evictMu   sync.RWMutex
+	onEvict   []func(key string)
+	evictions atomic.Uint64
+}
+
+// localCount is the size of the whole store, kept up to date by the shards.
+type localCount struct {
+	entries atomic.Int64
+	bytes   atomic.Int64
+}
+
+type localShard struct {
+	mu       sync.Mutex
+	entries  map[string]*localEntry
+	eviction evictionList
+	bytes    int64
+	count    *localCount
+}
+
+type localEntry struct {
+	key       string
+	value     any
+	cost      int64
+	expiresAt time.Time
+	// used by the eviction lists
+	element   *list.Element
+	index     int
+	frequency uint64
+	lastUsed  uint64
+}
+
+// CreateLocalStore creates an in-process store that keeps Go values as they are, without serialization,
+// so reads return the exact value (or pointer) that was stored. It is meant as tier 0 in front of
+// CreateMemoryStore or a remote store; values must not be mutated after being cached.
+func CreateLocalStore(name string, config LocalStoreConfig) interfaces.CacheStore {
+	shardCount := 16
+	if config.Shards > 0 {
+		shardCount = 1
+		for shardCount < config.Shards {
+			shardCount <<= 1
+		}
+	}
+	if config.Cost == nil {
+		config.Cost = EncodedSizeCost
+	}
+
+	store := &localStore{
+		name:   name,
+		config: config,
+		shards: make([]*localShard, shardCount),
+		mask:   uint64(shardCount - 1),
+		stop:   make(chan struct{}),
+	}
+
+	for i := range store.shards {
+		shard := &localShard{
+			entries: make(map[string]*localEntry),
+			count:   &store.count,
+		}
+		if config.Eviction == EvictLFU {
+			shard.eviction = &lfuHeap{}
+		} else {
+			shard.eviction = &lruList{list: list.New()}
+		}
+		store.shards[i] = shard
+	}
+
+	if config.CleanupInterval > 0 {
+		go store.cleanup(config.CleanupInterval)
+	}
+
+	return store
+}
+
+func (l *localStore) shard(key string) *localShard {
+	return l.shards[xxhash.Sum64String(key)&l.mask]
+}
+
+func (l *localStore) Name() string {
+	return l.name
+}
+
+func (l *localStore) Set(ctx context.Context, key string, value any, ttl time.Duration) error {
+	var cost int64
+	if l.config.MaxBytes > 0 {
+		cost = l.config.Cost(value)
+	}
+
+	var expiresAt time.Time
+	if ttl > 0 {
+		expiresAt = time.Now().Add(ttl)
+	}
+
+	entry := &localEntry{key: key, value: value, cost: cost, expiresAt: expiresAt}
+	shard := l.shard(key)
+	if !l.bounded() {
+		shard.mu.Lock()
+		shard.remove(key)
+		shard.add(entry, l.ticks.Add(1))
+		shard.mu.Unlock()
+		return nil
+	}
+
+	l.writeMu.Lock()
+	evicted := l.setBounded(shard, entry)
+	l.writeMu.Unlock()
+
+	if len(evicted) > 0 {
+		l.evictions.Add(uint64(len(evicted)))
+		l.evictMu.RLock()
+		defer l.evictMu.RUnlock()
+		for _, fn := range l.onEvict {
+			for _, evictedKey := range evicted {
+				fn(evictedKey)
+			}
+		}
+	}
+	return nil
+}
+
+// OnEvict implements interfaces.EvictionNotifier.
+func (l *localStore) OnEvict(fn func(key string)) {
+	l.evictMu.Lock()
+	defer l.evictMu.Unlock()
+	l.onEvict = append(l.onEvict, fn)
+}
+
+// Stats implements interfaces.StatsStore. Bytes is only tracked when MaxBytes is set.
+func (l *localStore) Stats(ctx context.Context) (interfaces.StoreStats, error) {
+	stats := interfaces.StoreStats{Evictions: l.evictions.Load()}
+	for _, shard := range l.shards {
+		shard.mu.Lock()
+		stats.Entries += int64(len(shard.entries))
+		stats.Bytes += shard.bytes
+		shard.mu.Unlock()
+	}
+	return stats, nil
+}
+
+func (l *localStore) bounded() bool {
+	return l.config.MaxEntries > 0 || l.config.MaxBytes > 0
+}
+
+// setBounded adds an entry to a bounded store, with writeMu held, and returns the keys evicted to make room for it.
+func (l *localStore) setBounded(shard *localShard, entry *localEntry) []string {
+	shard.mu.Lock()
+	shard.remove(entry.key)
+	shard.mu.Unlock()
+
+	if l.config.MaxBytes > 0 && entry.cost > l.config.MaxBytes {
+		// Would evict the whole store and still not fit, so it is not cached, like a rejected ristretto set
+		return nil
+	}
+
+	// Make room before adding, so a new entry is never its own victim
+	var evicted []string
+	for l.needsRoom(entry.cost) {
+		victim, ok := l.evictOne()
+		if !ok {
+			break
+		}
+		if victim != nil {
+			evicted = append(evicted, victim.key)
+		}
+	}
+
+	shard.mu.Lock()
+	shard.add(entry, l.ticks.Add(1))
+	shard.mu.Unlock()
+	return evicted
+}
+
+// needsRoom reports whether an entry of the given cost would exceed the store's bounds.
+func (l *localStore) needsRoom(cost int64) bool {
+	entries := l.count.entries.Load()
+	if entries == 0 {
+		return false
+	}
+	return (l.config.MaxEntries > 0 && entries+1 > int64(l.config.MaxEntries)) ||
+		(l.config.MaxBytes > 0 && l.count.bytes.Load()+cost > l.config.MaxBytes)
+}
+
+// evictOne removes the entry the eviction policy picks across all shards. It returns false when the
+// store is empty, and a nil entry when the victim was read or deleted before it could be removed.
+func (l *localStore) evictOne() (*localEntry, bool) {
+	var victim *localEntry
+	var victimShard *localShard
+	for _, shard := range l.shards {
+		shard.mu.Lock()
+		if len(shard.entries) > 0 {
+			candidate := shard.eviction.victim()
+			if victim == nil || l.evictsBefore(candidate, victim) {
+				victim = candidate
+				victimShard = shard
+			}
+		}
+		shard.mu.Unlock()
+	}
+	if victim == nil {
+		return nil, false
+	}
+
+	victimShard.mu.Lock()
+	defer victimShard.mu.Unlock()
+	// A delete may have got to it since, or a read made it a worse victim, then the next round picks again
+	if victimShard.entries[victim.key] != victim || victimShard.eviction.victim() != victim {
+		return nil, true
+	}
+	victimShard.remove(victim.key)
+	return victim, true
+}
+
+// evictsBefore compares the victims of two shards, the way each shard orders its own entries.
+func (l *localStore) evictsBefore(a, b *localEntry) bool {
+	if l.config.Eviction == EvictLFU && a.frequency != b.frequency {
+		return a.frequency < b.frequency
+	}
+	return a.lastUsed < b.lastUsed
+}
+
+func (l *localStore) Get(ctx context.Context, key string) (any, error) {
+	value, _, err := l.GetWithTTL(ctx, key)
+	return value, err
+}
+
+func (l *localStore) GetWithTTL(ctx context.Context, key string) (any, time.Duration, error) {
+	shard := l.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	entry, found := shard.entries[key]
+	if !found {
+		return nil, 0, interfaces.ErrNotFound
+	}
+
+	var remaining time.Duration
+	if !entry.expiresAt.IsZero() {
+		remaining = time.Until(entry.expiresAt)
+		if remaining <= 0 {
+			shard.remove(key)
+			return nil, 0, interfaces.ErrExpired
+		}
+	}
+
+	entry.lastUsed = l.ticks.Add(1)
+	shard.eviction.touch(entry)
+	return entry.value, remaining, nil
+}
+
+func (l *localStore) Delete(ctx context.Context, key string) error {
+	shard := l.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	shard.remove(key)
+	return nil
+}
+
+func (l *localStore) Clear(ctx context.Context) error {
+	for _, shard := range l.shards {
+		shard.mu.Lock()
+		l.count.entries.Add(-int64(len(shard.entries)))
+		l.count.bytes.Add(-shard.bytes)
+		shard.entries = make(map[string]*localEntry)
+		shard.eviction.reset()
+		shard.bytes = 0
+		shard.mu.Unlock()
+	}
+	return nil
+}
+
+func (l *localStore) Close() error {
+	l.once.Do(func() {
+		close(l.stop)
+	})
+	return nil
+}
+
+func (l *localStore) cleanup(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.stop:
+			return
+		case now := <-ticker.C:
+			for _, shard := range l.shards {
+				shard.mu.Lock()
+				for key, entry := range shard.entries {
+					if !entry.expiresAt.IsZero() && now.After(entry.expiresAt) {
+						shard.remove(key)
+					}
+				}
+				shard.mu.Unlock()
+			}
+		}
+	}
+}
+
+func (s *localShard) add(entry *localEntry, tick uint64) {
+	entry.lastUsed = tick
+	s.entries[entry.key] = entry
+	s.eviction.add(entry)
+	s.bytes += entry.cost
+	s.count.entries.Add(1)
+	s.count.bytes.Add(entry.cost)
+}
+
+func (s *localShard) remove(key string) {
+	entry, found := s.entries[key]
+	if !found {
+		return
+	}
+	delete(s.entries, key)
+	s.eviction.remove(entry)
+	s.bytes -= entry.cost
+	s.count.entries.Add(-1)
+	s.count.bytes.Add(-entry.cost)
+}
+
+// evictionList tracks entries in the order they should be evicted.
+type evictionList interface {
+	add(entry *localEntry)
+	touch(entry *localEntry)
+	remove(entry *localEntry)
+	victim() *localEntry
+	reset()
+}
+
+type lruList struct {
+	list *list.List
+}
+
+func (l *lruList) add(entry *localEntry) {
+	entry.element = l.list.PushFront(entry)
+}
+
+func (l *lruList) touch(entry *localEntry) {
+	l.list.MoveToFront(entry.element)
+}
+
+func (l *lruList) remove(entry *localEntry) {
+	l.list.Remove(entry.element)
+}
+
+func (l *lruList) victim() *localEntry {
+	return l.list.Back().Value.(*localEntry)
+}
+
+func (l *lruList) reset() {
+	l.list.Init()
+}
+
+// lfuHeap is a min-heap on access frequency, breaking ties on least recent use.
+type lfuHeap []*localEntry
+
+func (h lfuHeap) Len() int { return len(h) }
+
+func (h lfuHeap) Less(i, j int) bool {
+	if h[i].frequency != h[j].frequency {
+		return h[i].frequency < h[j].frequency
+	}
+	return h[i].lastUsed < h[j].lastUsed
+}
+
+func (h lfuHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *lfuHeap) Push(x any) {
+	entry := x.(*localEntry)
+	entry.index = len(*h)
+	*h = append(*h, entry)
+}
+
+func (h *lfuHeap) Pop() any {
+	old := *h
+	entry := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return entry
+}
+
+func (h *lfuHeap) add(entry *localEntry) {
+	entry.frequency = 1
+	heap.Push(h, entry)
+}
+
+func (h *lfuHeap) touch(entry *localEntry) {
+	entry.frequency++
+	heap.Fix(h, entry.index)
+}
+
+func (h *lfuHeap) remove(entry *localEntry) {
+	heap.Remove(h, entry.index)
+}
+
+func (h *lfuHeap) victim() *localEntry {
+	return (*h)[0]
+}
+
+func (h *lfuHeap) reset() {
+	// Cleared before truncating, so the backing array does not keep the removed entries alive
+	clear(*h)
+	*h = (*h)[:0]
+}
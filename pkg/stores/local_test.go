@@ -0,0 +1,227 @@
+package stores
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/reksie/tieredcache/pkg/interfaces"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocalSetGet(t *testing.T) {
+	ctx := context.Background()
+	store := CreateLocalStore("local", LocalStoreConfig{})
+	defer store.Close()
+	assert.Equal(t, "local", store.Name())
+
+	type TestPerson struct {
+		Name string
+	}
+	person := &TestPerson{Name: "John"}
+
+	assert.NoError(t, store.Set(ctx, "key1", person, time.Minute))
+
+	value, err := store.Get(ctx, "key1")
+	assert.NoError(t, err)
+	assert.Same(t, person, value)
+
+	_, err = store.Get(ctx, "non_existent_key")
+	assert.Error(t, err)
+}
+
+func TestLocalGetExpired(t *testing.T) {
+	ctx := context.Background()
+	store := CreateLocalStore("local", LocalStoreConfig{})
+	defer store.Close()
+
+	assert.NoError(t, store.Set(ctx, "key1", "value1", 5*time.Millisecond))
+	time.Sleep(10 * time.Millisecond)
+
+	_, err := store.Get(ctx, "key1")
+	assert.Error(t, err)
+}
+
+func TestLocalCleanup(t *testing.T) {
+	ctx := context.Background()
+	store := CreateLocalStore("local", LocalStoreConfig{Shards: 1, CleanupInterval: 5 * time.Millisecond})
+	defer store.Close()
+
+	assert.NoError(t, store.Set(ctx, "key1", "value1", 5*time.Millisecond))
+	time.Sleep(20 * time.Millisecond)
+
+	shard := store.(*localStore).shards[0]
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	assert.Empty(t, shard.entries)
+}
+
+func TestLocalDeleteAndClear(t *testing.T) {
+	ctx := context.Background()
+	store := CreateLocalStore("local", LocalStoreConfig{})
+	defer store.Close()
+
+	assert.NoError(t, store.Set(ctx, "key1", "value1", time.Minute))
+	assert.NoError(t, store.Set(ctx, "key2", "value2", time.Minute))
+
+	assert.NoError(t, store.Delete(ctx, "key1"))
+	_, err := store.Get(ctx, "key1")
+	assert.Error(t, err)
+
+	assert.NoError(t, store.Clear(ctx))
+	_, err = store.Get(ctx, "key2")
+	assert.Error(t, err)
+}
+
+func TestLocalEvictLRU(t *testing.T) {
+	ctx := context.Background()
+	store := CreateLocalStore("local", LocalStoreConfig{MaxEntries: 2, Eviction: EvictLRU})
+	defer store.Close()
+
+	store.Set(ctx, "a", 1, time.Minute)
+	store.Set(ctx, "b", 2, time.Minute)
+	store.Get(ctx, "a")
+	store.Set(ctx, "c", 3, time.Minute)
+
+	_, err := store.Get(ctx, "b")
+	assert.Error(t, err, "least recently used entry should be evicted")
+	_, err = store.Get(ctx, "a")
+	assert.NoError(t, err)
+	_, err = store.Get(ctx, "c")
+	assert.NoError(t, err)
+}
+
+func TestLocalEvictLFU(t *testing.T) {
+	ctx := context.Background()
+	store := CreateLocalStore("local", LocalStoreConfig{MaxEntries: 2, Eviction: EvictLFU})
+	defer store.Close()
+
+	store.Set(ctx, "a", 1, time.Minute)
+	store.Set(ctx, "b", 2, time.Minute)
+	store.Get(ctx, "a")
+	store.Get(ctx, "a")
+	store.Get(ctx, "b")
+	store.Set(ctx, "c", 3, time.Minute)
+
+	_, err := store.Get(ctx, "b")
+	assert.Error(t, err, "least frequently used entry should be evicted")
+	_, err = store.Get(ctx, "a")
+	assert.NoError(t, err)
+}
+
+func TestLocalOnEvict(t *testing.T) {
+	ctx := context.Background()
+	store := CreateLocalStore("local", LocalStoreConfig{MaxEntries: 2})
+	defer store.Close()
+
+	var evicted []string
+	store.(interfaces.EvictionNotifier).OnEvict(func(key string) {
+		evicted = append(evicted, key)
+	})
+
+	store.Set(ctx, "a", 1, time.Minute)
+	store.Set(ctx, "b", 2, time.Minute)
+	store.Delete(ctx, "b")
+	store.Set(ctx, "b", 2, time.Minute)
+	store.Set(ctx, "c", 3, time.Minute)
+
+	assert.Equal(t, []string{"a"}, evicted)
+}
+
+func TestLocalStats(t *testing.T) {
+	ctx := context.Background()
+	store := CreateLocalStore("local", LocalStoreConfig{Shards: 2, MaxEntries: 2, MaxBytes: 100})
+	defer store.Close()
+
+	for i := 0; i < 5; i++ {
+		store.Set(ctx, fmt.Sprintf("key%d", i), "12345", time.Minute)
+	}
+
+	stats, err := store.(interfaces.StatsStore).Stats(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, interfaces.StoreStats{Entries: 2, Bytes: 10, Evictions: 3}, stats)
+}
+
+func TestLocalBoundsAcrossShards(t *testing.T) {
+	ctx := context.Background()
+	for _, maxEntries := range []int{1, 16, 50} {
+		// The default 16 shards
+		store := CreateLocalStore("local", LocalStoreConfig{MaxEntries: maxEntries})
+		for i := 0; i < 100; i++ {
+			store.Set(ctx, fmt.Sprintf("key%d", i), i, time.Minute)
+		}
+
+		stats, err := store.(interfaces.StatsStore).Stats(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(maxEntries), stats.Entries)
+		assert.Equal(t, uint64(100-maxEntries), stats.Evictions)
+
+		// Least recently used first, whatever shard it is in
+		for i := 100 - maxEntries; i < 100; i++ {
+			_, err := store.Get(ctx, fmt.Sprintf("key%d", i))
+			assert.NoError(t, err)
+		}
+		store.Close()
+	}
+
+	store := CreateLocalStore("local", LocalStoreConfig{MaxBytes: 20})
+	defer store.Close()
+	for i := 0; i < 10; i++ {
+		store.Set(ctx, fmt.Sprintf("key%d", i), "12345", time.Minute)
+	}
+	stats, err := store.(interfaces.StatsStore).Stats(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, interfaces.StoreStats{Entries: 4, Bytes: 20, Evictions: 6}, stats)
+}
+
+func TestLocalMaxBytes(t *testing.T) {
+	ctx := context.Background()
+	store := CreateLocalStore("local", LocalStoreConfig{MaxBytes: 10})
+	defer store.Close()
+
+	store.Set(ctx, "a", "12345", time.Minute)
+	store.Set(ctx, "b", "12345", time.Minute)
+	store.Set(ctx, "c", "12345", time.Minute)
+
+	_, err := store.Get(ctx, "a")
+	assert.Error(t, err)
+	_, err = store.Get(ctx, "c")
+	assert.NoError(t, err)
+
+	// Too large to ever fit, so it is not stored
+	assert.NoError(t, store.Set(ctx, "big", "this is more than ten bytes", time.Minute))
+	_, err = store.Get(ctx, "big")
+	assert.Error(t, err)
+}
+
+func TestLocalConcurrent(t *testing.T) {
+	ctx := context.Background()
+	store := CreateLocalStore("local", LocalStoreConfig{MaxEntries: 100, Eviction: EvictLFU})
+	defer store.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 500; j++ {
+				key := fmt.Sprintf("key%d", (i*j)%200)
+				store.Set(ctx, key, j, time.Minute)
+				store.Get(ctx, key)
+				if j%10 == 0 {
+					store.Delete(ctx, key)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	total := 0
+	for _, shard := range store.(*localStore).shards {
+		total += len(shard.entries)
+	}
+	assert.LessOrEqual(t, total, 100)
+	assert.Equal(t, int64(total), store.(*localStore).count.entries.Load())
+}
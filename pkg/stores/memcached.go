@@ -0,0 +1,409 @@
+package stores
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/reksie/tieredcache/pkg/interfaces"
+	"github.com/reksie/tieredcache/pkg/keys"
+)
+
+const (
+	memcachedMaxKeyLength = 250
+	// Memcached treats expiry times above 30 days as an absolute unix timestamp
+	memcachedMaxRelativeExpiry = 30 * 24 * time.Hour
+	// Leaves room for the generation and a hashed key after the namespace,
+	// as in "namespace:18446744073709551615:#" followed by a SHA-256 hex digest
+	memcachedMaxNamespaceLength = memcachedMaxKeyLength - len(":18446744073709551615:#") - 64
+)
+
+type MemcachedStoreConfig struct {
+	// Namespace prefixes every key, so several stores can share a server.
+	Namespace string
+	// Timeout bounds dialing and each request, defaults to one second.
+	Timeout time.Duration
+	// MaxIdleConns is the number of connections kept open between requests, defaults to 2.
+	MaxIdleConns int
+	// GenerationRefresh is how long the namespace generation is cached locally, defaults to one second.
+	// A Clear from another process is seen after at most this long.
+	GenerationRefresh time.Duration
+}
+
+type memcachedStore struct {
+	name   string
+	addr   string
+	config MemcachedStoreConfig
+	idle   chan *memcachedConn
+
+	mu                  sync.Mutex
+	generation          uint64
+	generationFetchedAt time.Time
+}
+
+type memcachedConn struct {
+	conn net.Conn
+	rw   *bufio.ReadWriter
+}
+
+// CreateMemcachedStore creates a store talking the memcached text protocol to addr.
+//
+// Memcached has no way to delete keys by prefix, and flush_all would wipe every other user of the server,
+// so Clear is implemented with a namespace generation: every key includes a generation number that is
+// stored on the server, and Clear increments it. Entries of older generations are never read again
+// and are left to expire or be evicted by memcached.
+//
+// The namespace is part of every key, so it must be a valid memcached key of at most 163 bytes.
+func CreateMemcachedStore(name string, addr string, config MemcachedStoreConfig) (interfaces.CacheStore, error) {
+	if config.Namespace != "" && !validMemcachedKey(config.Namespace) {
+		return nil, fmt.Errorf("invalid memcached namespace %q", config.Namespace)
+	}
+	if len(config.Namespace) > memcachedMaxNamespaceLength {
+		return nil, fmt.Errorf("memcached namespace is longer than %d bytes", memcachedMaxNamespaceLength)
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = time.Second
+	}
+	if config.MaxIdleConns <= 0 {
+		config.MaxIdleConns = 2
+	}
+	if config.GenerationRefresh <= 0 {
+		config.GenerationRefresh = time.Second
+	}
+
+	return &memcachedStore{
+		name:   name,
+		addr:   addr,
+		config: config,
+		idle:   make(chan *memcachedConn, config.MaxIdleConns),
+	}, nil
+}
+
+func (m *memcachedStore) Name() string {
+	return m.name
+}
+
+func (m *memcachedStore) Set(ctx context.Context, key string, value any, ttl time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	fullKey, err := m.key(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	return m.do(ctx, func(c *memcachedConn) error {
+		fmt.Fprintf(c.rw, "set %s 0 %d %d\r\n", fullKey, memcachedExpiry(ttl, time.Now()), len(data))
+		c.rw.Write(data)
+		c.rw.WriteString("\r\n")
+		return c.expect("STORED")
+	})
+}
+
+func (m *memcachedStore) Get(ctx context.Context, key string) (any, error) {
+	fullKey, err := m.key(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := m.get(ctx, fullKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, fmt.Errorf("%w: %v", interfaces.ErrCorrupt, err)
+	}
+	return value, nil
+}
+
+func (m *memcachedStore) Delete(ctx context.Context, key string) error {
+	fullKey, err := m.key(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	return m.do(ctx, func(c *memcachedConn) error {
+		fmt.Fprintf(c.rw, "delete %s\r\n", fullKey)
+		line, err := c.readLine()
+		if err != nil {
+			return err
+		}
+		if line != "DELETED" && line != "NOT_FOUND" {
+			return memcachedError(line)
+		}
+		return nil
+	})
+}
+
+// Clear moves the namespace to a new generation, see CreateMemcachedStore.
+func (m *memcachedStore) Clear(ctx context.Context) error {
+	generationKey := m.generationKey()
+
+	var generation uint64
+	err := m.do(ctx, func(c *memcachedConn) error {
+		fmt.Fprintf(c.rw, "incr %s 1\r\n", generationKey)
+		line, err := c.readLine()
+		if err != nil {
+			return err
+		}
+		if line == "NOT_FOUND" {
+			return interfaces.ErrNotFound
+		}
+		generation, err = strconv.ParseUint(line, 10, 64)
+		if err != nil {
+			return memcachedError(line)
+		}
+		return nil
+	})
+	if errors.Is(err, interfaces.ErrNotFound) {
+		// The generation was evicted, starting a new one is just as good
+		generation, err = m.initGeneration(ctx)
+	}
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	m.generation = generation
+	m.generationFetchedAt = time.Now()
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *memcachedStore) Close() error {
+	for {
+		select {
+		case c := <-m.idle:
+			c.conn.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+// memcachedExpiry converts a TTL into memcached's exptime, which is relative up to 30 days and absolute above.
+func memcachedExpiry(ttl time.Duration, now time.Time) int64 {
+	if ttl <= 0 {
+		return 0
+	}
+	if ttl > memcachedMaxRelativeExpiry {
+		return now.Add(ttl).Unix()
+	}
+	// Round up, an exptime of 0 would never expire
+	seconds := int64((ttl + time.Second - 1) / time.Second)
+	return seconds
+}
+
+// key builds the full memcached key, hashing keys that are too long or contain characters memcached does not allow.
+func (m *memcachedStore) key(ctx context.Context, key string) (string, error) {
+	generation, err := m.currentGeneration(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	prefix := m.config.Namespace + ":" + strconv.FormatUint(generation, 10) + ":"
+	if validMemcachedKey(key) && !strings.HasPrefix(key, "#") && len(prefix)+len(key) <= memcachedMaxKeyLength {
+		return prefix + key, nil
+	}
+
+	hash, err := keys.HashKeySHA256(key)
+	if err != nil {
+		return "", err
+	}
+	// # marks hashed keys, so they can never collide with a plain key
+	return prefix + "#" + hash, nil
+}
+
+func validMemcachedKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] <= ' ' || key[i] == 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *memcachedStore) generationKey() string {
+	return m.config.Namespace + ":generation"
+}
+
+func (m *memcachedStore) currentGeneration(ctx context.Context) (uint64, error) {
+	m.mu.Lock()
+	if m.generation != 0 && time.Since(m.generationFetchedAt) < m.config.GenerationRefresh {
+		generation := m.generation
+		m.mu.Unlock()
+		return generation, nil
+	}
+	m.mu.Unlock()
+
+	var generation uint64
+	data, err := m.get(ctx, m.generationKey())
+	if errors.Is(err, interfaces.ErrNotFound) {
+		generation, err = m.initGeneration(ctx)
+	} else if err == nil {
+		generation, err = strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	m.mu.Lock()
+	m.generation = generation
+	m.generationFetchedAt = time.Now()
+	m.mu.Unlock()
+	return generation, nil
+}
+
+// initGeneration starts a new generation. It starts from the current time rather than 1, so a generation
+// key that was evicted never restarts at a number whose entries are still around.
+func (m *memcachedStore) initGeneration(ctx context.Context) (uint64, error) {
+	generation := uint64(time.Now().UnixNano())
+	data := strconv.FormatUint(generation, 10)
+
+	err := m.do(ctx, func(c *memcachedConn) error {
+		fmt.Fprintf(c.rw, "add %s 0 0 %d\r\n%s\r\n", m.generationKey(), len(data), data)
+		line, err := c.readLine()
+		if err != nil {
+			return err
+		}
+		if line == "NOT_STORED" {
+			// Another process won the race
+			return interfaces.ErrNotFound
+		}
+		if line != "STORED" {
+			return memcachedError(line)
+		}
+		return nil
+	})
+	if errors.Is(err, interfaces.ErrNotFound) {
+		existing, err := m.get(ctx, m.generationKey())
+		if err != nil {
+			return 0, err
+		}
+		return strconv.ParseUint(strings.TrimSpace(string(existing)), 10, 64)
+	}
+	return generation, err
+}
+
+func (m *memcachedStore) get(ctx context.Context, fullKey string) ([]byte, error) {
+	var data []byte
+	err := m.do(ctx, func(c *memcachedConn) error {
+		fmt.Fprintf(c.rw, "get %s\r\n", fullKey)
+		line, err := c.readLine()
+		if err != nil {
+			return err
+		}
+		if line == "END" {
+			return interfaces.ErrNotFound
+		}
+
+		// VALUE <key> <flags> <bytes>
+		fields := strings.Fields(line)
+		if len(fields) != 4 || fields[0] != "VALUE" {
+			return memcachedError(line)
+		}
+		size, err := strconv.Atoi(fields[3])
+		if err != nil {
+			return memcachedError(line)
+		}
+
+		data = make([]byte, size+2)
+		if _, err := io.ReadFull(c.rw, data); err != nil {
+			return err
+		}
+		data = data[:size]
+		return c.expect("END")
+	})
+	return data, err
+}
+
+// do runs a request on a pooled connection. Connections are only reused after a successful
+// request or a well formed miss, so a half read response never leaks into the next request.
+func (m *memcachedStore) do(ctx context.Context, request func(c *memcachedConn) error) error {
+	c, err := m.conn(ctx)
+	if err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(m.config.Timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	c.conn.SetDeadline(deadline)
+
+	err = request(c)
+	if err == nil {
+		err = c.rw.Flush()
+	}
+	if err != nil && !errors.Is(err, interfaces.ErrNotFound) {
+		c.conn.Close()
+		return err
+	}
+
+	select {
+	case m.idle <- c:
+	default:
+		c.conn.Close()
+	}
+	return err
+}
+
+func (m *memcachedStore) conn(ctx context.Context) (*memcachedConn, error) {
+	select {
+	case c := <-m.idle:
+		return c, nil
+	default:
+	}
+
+	dialer := net.Dialer{Timeout: m.config.Timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", m.addr)
+	if err != nil {
+		return nil, err
+	}
+	return &memcachedConn{
+		conn: conn,
+		rw:   bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn)),
+	}, nil
+}
+
+// readLine flushes the pending request and reads a response line.
+func (c *memcachedConn) readLine() (string, error) {
+	if err := c.rw.Flush(); err != nil {
+		return "", err
+	}
+	line, err := c.rw.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func (c *memcachedConn) expect(expected string) error {
+	line, err := c.readLine()
+	if err != nil {
+		return err
+	}
+	if line != expected {
+		return memcachedError(line)
+	}
+	return nil
+}
+
+func memcachedError(line string) error {
+	return fmt.Errorf("memcached: unexpected response %q", line)
+}
@@ -0,0 +1,288 @@
+package stores
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/reksie/tieredcache/pkg/interfaces"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeMemcached is an in-process server implementing the part of the memcached text protocol used by the store.
+type fakeMemcached struct {
+	listener net.Listener
+	mu       sync.Mutex
+	items    map[string]fakeMemcachedItem
+	// keys seen by set, to check key sanitation
+	setKeys []string
+}
+
+type fakeMemcachedItem struct {
+	data      []byte
+	expiresAt time.Time
+}
+
+func startFakeMemcached(t *testing.T) *fakeMemcached {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	server := &fakeMemcached{listener: listener, items: make(map[string]fakeMemcachedItem)}
+	go server.serve()
+	t.Cleanup(func() { listener.Close() })
+	return server
+}
+
+func (f *fakeMemcached) addr() string {
+	return f.listener.Addr().String()
+}
+
+func (f *fakeMemcached) serve() {
+	for {
+		conn, err := f.listener.Accept()
+		if err != nil {
+			return
+		}
+		go f.handle(conn)
+	}
+}
+
+func (f *fakeMemcached) handle(conn net.Conn) {
+	defer conn.Close()
+	rw := bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
+
+	for {
+		line, err := rw.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			return
+		}
+
+		f.mu.Lock()
+		switch fields[0] {
+		case "set", "add":
+			size, _ := strconv.Atoi(fields[4])
+			data := make([]byte, size+2)
+			io.ReadFull(rw, data)
+			exptime, _ := strconv.ParseInt(fields[3], 10, 64)
+
+			if _, exists := f.lookup(fields[1]); fields[0] == "add" && exists {
+				rw.WriteString("NOT_STORED\r\n")
+				break
+			}
+			if len(fields[1]) > memcachedMaxKeyLength {
+				rw.WriteString("CLIENT_ERROR key too long\r\n")
+				break
+			}
+			f.setKeys = append(f.setKeys, fields[1])
+			f.items[fields[1]] = fakeMemcachedItem{data: data[:size], expiresAt: fakeMemcachedExpiry(exptime)}
+			rw.WriteString("STORED\r\n")
+		case "get":
+			if item, ok := f.lookup(fields[1]); ok {
+				fmt.Fprintf(rw, "VALUE %s 0 %d\r\n%s\r\n", fields[1], len(item.data), item.data)
+			}
+			rw.WriteString("END\r\n")
+		case "delete":
+			if _, ok := f.lookup(fields[1]); ok {
+				delete(f.items, fields[1])
+				rw.WriteString("DELETED\r\n")
+			} else {
+				rw.WriteString("NOT_FOUND\r\n")
+			}
+		case "incr":
+			item, ok := f.lookup(fields[1])
+			if !ok {
+				rw.WriteString("NOT_FOUND\r\n")
+				break
+			}
+			value, _ := strconv.ParseUint(string(item.data), 10, 64)
+			delta, _ := strconv.ParseUint(fields[2], 10, 64)
+			item.data = []byte(strconv.FormatUint(value+delta, 10))
+			f.items[fields[1]] = item
+			fmt.Fprintf(rw, "%s\r\n", item.data)
+		default:
+			rw.WriteString("ERROR\r\n")
+		}
+		f.mu.Unlock()
+		rw.Flush()
+	}
+}
+
+func (f *fakeMemcached) lookup(key string) (fakeMemcachedItem, bool) {
+	item, ok := f.items[key]
+	if ok && !item.expiresAt.IsZero() && time.Now().After(item.expiresAt) {
+		delete(f.items, key)
+		return item, false
+	}
+	return item, ok
+}
+
+func fakeMemcachedExpiry(exptime int64) time.Time {
+	switch {
+	case exptime == 0:
+		return time.Time{}
+	case exptime > int64(memcachedMaxRelativeExpiry/time.Second):
+		return time.Unix(exptime, 0)
+	default:
+		return time.Now().Add(time.Duration(exptime) * time.Second)
+	}
+}
+
+func TestMemcachedSetGet(t *testing.T) {
+	ctx := context.Background()
+	store, err := CreateMemcachedStore("memcached", startFakeMemcached(t).addr(), MemcachedStoreConfig{Namespace: "test"})
+	assert.NoError(t, err)
+	defer store.Close()
+	assert.Equal(t, "memcached", store.Name())
+
+	assert.NoError(t, store.Set(ctx, "key1", map[string]any{"name": "John"}, time.Minute))
+
+	value, err := store.Get(ctx, "key1")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"name": "John"}, value)
+
+	_, err = store.Get(ctx, "non_existent_key")
+	assert.Error(t, err)
+}
+
+func TestMemcachedGetExpired(t *testing.T) {
+	ctx := context.Background()
+	store, err := CreateMemcachedStore("memcached", startFakeMemcached(t).addr(), MemcachedStoreConfig{Namespace: "test"})
+	assert.NoError(t, err)
+	defer store.Close()
+
+	// Sub-second TTLs round up to a second rather than becoming 0, which would never expire
+	assert.NoError(t, store.Set(ctx, "key1", "value1", 10*time.Millisecond))
+	_, err = store.Get(ctx, "key1")
+	assert.NoError(t, err)
+
+	time.Sleep(1100 * time.Millisecond)
+
+	_, err = store.Get(ctx, "key1")
+	assert.Error(t, err)
+}
+
+func TestMemcachedExpiry(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	assert.Equal(t, int64(0), memcachedExpiry(0, now))
+	assert.Equal(t, int64(1), memcachedExpiry(time.Millisecond, now))
+	assert.Equal(t, int64(60), memcachedExpiry(time.Minute, now))
+	assert.Equal(t, int64(2592000), memcachedExpiry(30*24*time.Hour, now))
+	// Above 30 days memcached expects an absolute timestamp
+	assert.Equal(t, now.Add(31*24*time.Hour).Unix(), memcachedExpiry(31*24*time.Hour, now))
+}
+
+func TestMemcachedKeySanitation(t *testing.T) {
+	ctx := context.Background()
+	server := startFakeMemcached(t)
+	store, err := CreateMemcachedStore("memcached", server.addr(), MemcachedStoreConfig{Namespace: "test"})
+	assert.NoError(t, err)
+	defer store.Close()
+
+	longKey := strings.Repeat("k", 300)
+	for _, key := range []string{"with spaces", "with\nnewline", longKey, "#hash"} {
+		assert.NoError(t, store.Set(ctx, key, key, time.Minute))
+		value, err := store.Get(ctx, key)
+		assert.NoError(t, err)
+		assert.Equal(t, key, value)
+	}
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	for _, key := range server.setKeys {
+		assert.True(t, validMemcachedKey(key), "invalid key %q", key)
+		assert.LessOrEqual(t, len(key), memcachedMaxKeyLength)
+	}
+}
+
+func TestMemcachedDeleteAndClear(t *testing.T) {
+	ctx := context.Background()
+	server := startFakeMemcached(t)
+	store, err := CreateMemcachedStore("memcached", server.addr(), MemcachedStoreConfig{Namespace: "test"})
+	assert.NoError(t, err)
+	defer store.Close()
+	other, err := CreateMemcachedStore("memcached", server.addr(), MemcachedStoreConfig{Namespace: "other"})
+	assert.NoError(t, err)
+	defer other.Close()
+
+	assert.NoError(t, store.Set(ctx, "key1", "value1", time.Minute))
+	assert.NoError(t, store.Set(ctx, "key2", "value2", time.Minute))
+	assert.NoError(t, other.Set(ctx, "key2", "other", time.Minute))
+
+	assert.NoError(t, store.Delete(ctx, "key1"))
+	_, err = store.Get(ctx, "key1")
+	assert.Error(t, err)
+
+	assert.NoError(t, store.Clear(ctx))
+	_, err = store.Get(ctx, "key2")
+	assert.Error(t, err)
+
+	// Clear only affects its own namespace
+	value, err := other.Get(ctx, "key2")
+	assert.NoError(t, err)
+	assert.Equal(t, "other", value)
+
+	// A second store on the namespace sees the Clear once it refreshes the generation
+	fresh, err := CreateMemcachedStore("memcached", server.addr(), MemcachedStoreConfig{Namespace: "test"})
+	assert.NoError(t, err)
+	defer fresh.Close()
+	_, err = fresh.Get(ctx, "key2")
+	assert.Error(t, err)
+}
+
+func TestMemcachedCorruptEntry(t *testing.T) {
+	ctx := context.Background()
+	server := startFakeMemcached(t)
+	store, err := CreateMemcachedStore("memcached", server.addr(), MemcachedStoreConfig{Namespace: "test"})
+	assert.NoError(t, err)
+	defer store.Close()
+
+	assert.NoError(t, store.Set(ctx, "key1", "value1", time.Minute))
+	server.mu.Lock()
+	for key, item := range server.items {
+		if strings.HasSuffix(key, ":key1") {
+			item.data = []byte("{truncated")
+			server.items[key] = item
+		}
+	}
+	server.mu.Unlock()
+
+	_, err = store.Get(ctx, "key1")
+	assert.ErrorIs(t, err, interfaces.ErrCorrupt)
+}
+
+func TestMemcachedInvalidNamespace(t *testing.T) {
+	for _, namespace := range []string{"with spaces", "with\nnewline", strings.Repeat("n", memcachedMaxNamespaceLength+1)} {
+		_, err := CreateMemcachedStore("memcached", "127.0.0.1:1", MemcachedStoreConfig{Namespace: namespace})
+		assert.Error(t, err)
+	}
+
+	// The longest namespace still leaves room for the generation and hashed keys
+	server := startFakeMemcached(t)
+	store, err := CreateMemcachedStore("memcached", server.addr(), MemcachedStoreConfig{Namespace: strings.Repeat("n", memcachedMaxNamespaceLength)})
+	assert.NoError(t, err)
+	defer store.Close()
+	assert.NoError(t, store.Set(context.Background(), strings.Repeat("k", 300), "value1", time.Minute))
+	assert.NoError(t, store.Clear(context.Background()))
+}
+
+func TestMemcachedConnectionError(t *testing.T) {
+	ctx := context.Background()
+	store, err := CreateMemcachedStore("memcached", "127.0.0.1:1", MemcachedStoreConfig{Timeout: 100 * time.Millisecond})
+	assert.NoError(t, err)
+
+	assert.Error(t, store.Set(ctx, "key1", "value1", time.Minute))
+	_, err = store.Get(ctx, "key1")
+	assert.Error(t, err)
+}
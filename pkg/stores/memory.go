@@ -3,7 +3,7 @@ package stores
 import (
 	"context"
 	"encoding/json"
-	"errors"
+	"fmt"
 	"time"
 
 	"github.com/allegro/bigcache/v3"
@@ -32,7 +32,10 @@ func (b *bigCacheStore) Name() string {
 }
 
 func (b *bigCacheStore) Set(ctx context.Context, key string, value any, ttl time.Duration) error {
-	// ctx is ignored for BigCache
+	// BigCache takes no context, so ctx is only checked for cancellation before starting
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	item := cacheItem{
 		Value:     value,
 		ExpiresAt: time.Now().Add(ttl),
@@ -41,43 +44,74 @@ func (b *bigCacheStore) Set(ctx context.Context, key string, value any, ttl time
 	if err != nil {
 		return err
 	}
+	// Encoding large values takes a while
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	return b.cache.Set(key, data)
 }
 
 func (b *bigCacheStore) Get(ctx context.Context, key string) (any, error) {
-	// ctx is ignored for BigCache
+	value, _, err := b.GetWithTTL(ctx, key)
+	return value, err
+}
+
+func (b *bigCacheStore) GetWithTTL(ctx context.Context, key string) (any, time.Duration, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, 0, err
+	}
 	data, err := b.cache.Get(key)
 	if err != nil {
 		if err == bigcache.ErrEntryNotFound {
-			return nil, errors.New("key not found in cache")
+			return nil, 0, interfaces.ErrNotFound
 		}
-		return nil, err
+		return nil, 0, err
 	}
 
 	var item cacheItem
 	err = json.Unmarshal(data, &item)
 	if err != nil {
-		return nil, err
+		return nil, 0, fmt.Errorf("%w: %v", interfaces.ErrCorrupt, err)
 	}
 
-	if time.Now().After(item.ExpiresAt) {
+	remaining := time.Until(item.ExpiresAt)
+	if remaining <= 0 {
 		b.cache.Delete(key)
-		return nil, errors.New("key expired")
+		return nil, 0, interfaces.ErrExpired
 	}
 
-	return item.Value, nil
+	return item.Value, remaining, nil
 }
 
 func (b *bigCacheStore) Delete(ctx context.Context, key string) error {
-	// ctx is ignored for BigCache
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	return b.cache.Delete(key)
 }
 
 func (b *bigCacheStore) Clear(ctx context.Context) error {
-	// ctx is ignored for BigCache
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	return b.cache.Reset()
 }
 
+// Stats implements interfaces.StatsStore with bigcache's own counters. Bytes is the capacity of its
+// shards, which it allocates ahead of use. bigcache does not count evictions.
+func (b *bigCacheStore) Stats(ctx context.Context) (interfaces.StoreStats, error) {
+	if err := ctx.Err(); err != nil {
+		return interfaces.StoreStats{}, err
+	}
+	stats := b.cache.Stats()
+	return interfaces.StoreStats{
+		Entries: int64(b.cache.Len()),
+		Bytes:   int64(b.cache.Capacity()),
+		Hits:    uint64(stats.Hits),
+		Misses:  uint64(stats.Misses),
+	}, nil
+}
+
 func (b *bigCacheStore) Close() error {
 	return b.cache.Close()
 }
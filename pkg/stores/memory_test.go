@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/allegro/bigcache/v3"
+	"github.com/reksie/tieredcache/pkg/interfaces"
 )
 
 func createTestCache() (*bigcache.BigCache, error) {
@@ -152,3 +153,46 @@ func TestClose(t *testing.T) {
 		t.Errorf("Unexpected error: %v", err)
 	}
 }
+
+func TestCanceledContext(t *testing.T) {
+	cache, err := createTestCache()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	store := CreateMemoryStore("test_store", cache)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := store.Set(ctx, "key1", "value1", time.Minute); err != context.Canceled {
+		t.Errorf("Expected context.Canceled from Set, got %v", err)
+	}
+	if _, err := store.Get(ctx, "key1"); err != context.Canceled {
+		t.Errorf("Expected context.Canceled from Get, got %v", err)
+	}
+}
+
+func TestMemoryStats(t *testing.T) {
+	ctx := context.Background()
+	cache, err := createTestCache()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	store := CreateMemoryStore("test_store", cache)
+
+	store.Set(ctx, "key1", "value1", time.Minute)
+	store.Set(ctx, "key2", "value2", time.Minute)
+	store.Get(ctx, "key1")
+	store.Get(ctx, "non_existent_key")
+
+	stats, err := store.(interfaces.StatsStore).Stats(ctx)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if stats.Entries != 2 || stats.Hits != 1 || stats.Misses != 1 {
+		t.Errorf("Expected 2 entries, 1 hit and 1 miss, got %+v", stats)
+	}
+	if stats.Bytes <= 0 {
+		t.Errorf("Expected the capacity in Bytes, got %d", stats.Bytes)
+	}
+}
@@ -0,0 +1,260 @@
+package stores
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/reksie/tieredcache/pkg/interfaces"
+)
+
+// Middleware wraps a store to add behaviour around its operations.
+type Middleware func(interfaces.CacheStore) interfaces.CacheStore
+
+// Chain wraps store in the middlewares, the first middleware being the outermost.
+func Chain(store interfaces.CacheStore, middlewares ...Middleware) interfaces.CacheStore {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		store = middlewares[i](store)
+	}
+	return store
+}
+
+// Op names a store operation for interceptors.
+type Op string
+
+const (
+	OpGet     Op = "get"
+	OpSet     Op = "set"
+	OpDelete  Op = "delete"
+	OpClear   Op = "clear"
+	OpGetMany Op = "get_many"
+	OpSetMany Op = "set_many"
+)
+
+// IsWrite reports whether the operation changes the store.
+func (op Op) IsWrite() bool {
+	return op == OpSet || op == OpDelete || op == OpClear || op == OpSetMany
+}
+
+// Interceptor runs around a store operation, calling next to run it. key is empty for Clear and the batch operations.
+type Interceptor func(ctx context.Context, op Op, key string, next func(ctx context.Context) error) error
+
+// Intercept builds a middleware running interceptor around every operation but Name, Close, Stats and OnEvict.
+// The wrapped store implements every optional interface and forwards to the store it wraps, falling back
+// to its CacheStore methods, so TieredCache keeps promoting with the remaining TTL, batching, reading
+// stats and receiving evictions through the chain.
+func Intercept(interceptor Interceptor) Middleware {
+	return func(store interfaces.CacheStore) interfaces.CacheStore {
+		return &interceptedStore{store: store, intercept: interceptor}
+	}
+}
+
+type interceptedStore struct {
+	store     interfaces.CacheStore
+	intercept Interceptor
+}
+
+func (s *interceptedStore) Name() string {
+	return s.store.Name()
+}
+
+func (s *interceptedStore) Set(ctx context.Context, key string, value any, ttl time.Duration) error {
+	return s.intercept(ctx, OpSet, key, func(ctx context.Context) error {
+		return s.store.Set(ctx, key, value, ttl)
+	})
+}
+
+func (s *interceptedStore) Get(ctx context.Context, key string) (any, error) {
+	var value any
+	err := s.intercept(ctx, OpGet, key, func(ctx context.Context) error {
+		var err error
+		value, err = s.store.Get(ctx, key)
+		return err
+	})
+	if err != nil {
+		// next may still be running when an interceptor gave up on it, so its results are only read on success
+		return nil, err
+	}
+	return value, nil
+}
+
+func (s *interceptedStore) Delete(ctx context.Context, key string) error {
+	return s.intercept(ctx, OpDelete, key, func(ctx context.Context) error {
+		return s.store.Delete(ctx, key)
+	})
+}
+
+func (s *interceptedStore) Clear(ctx context.Context) error {
+	return s.intercept(ctx, OpClear, "", func(ctx context.Context) error {
+		return s.store.Clear(ctx)
+	})
+}
+
+func (s *interceptedStore) Close() error {
+	return s.store.Close()
+}
+
+func (s *interceptedStore) GetWithTTL(ctx context.Context, key string) (any, time.Duration, error) {
+	var value any
+	var ttl time.Duration
+	err := s.intercept(ctx, OpGet, key, func(ctx context.Context) error {
+		var err error
+		value, ttl, err = getWithTTL(ctx, s.store, key)
+		return err
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return value, ttl, nil
+}
+
+func (s *interceptedStore) GetMany(ctx context.Context, keys []string) (map[string]any, error) {
+	var values map[string]any
+	err := s.intercept(ctx, OpGetMany, "", func(ctx context.Context) error {
+		var err error
+		values, err = getMany(ctx, s.store, keys)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+func (s *interceptedStore) SetMany(ctx context.Context, values map[string]any, ttl time.Duration) error {
+	return s.intercept(ctx, OpSetMany, "", func(ctx context.Context) error {
+		return setMany(ctx, s.store, values, ttl)
+	})
+}
+
+func (s *interceptedStore) Stats(ctx context.Context) (interfaces.StoreStats, error) {
+	return storeStats(ctx, s.store)
+}
+
+func (s *interceptedStore) OnEvict(fn func(key string)) {
+	onEvict(s.store, fn)
+}
+
+// WithLogging logs every operation at debug level, and failures other than misses at warn level.
+// Keys are logged hashed, as they may hold user data. A nil logger uses slog.Default().
+func WithLogging(logger *slog.Logger) Middleware {
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	return func(store interfaces.CacheStore) interfaces.CacheStore {
+		name := store.Name()
+		return Intercept(func(ctx context.Context, op Op, key string, next func(ctx context.Context) error) error {
+			start := time.Now()
+			err := next(ctx)
+
+			level := slog.LevelDebug
+			if err != nil && !errors.Is(err, interfaces.ErrNotFound) {
+				level = slog.LevelWarn
+			}
+			logger.Log(ctx, level, "cache store operation",
+				slog.String("store", name),
+				slog.String("op", string(op)),
+				slog.String("key", hashKey(key)),
+				slog.Duration("duration", time.Since(start)),
+				slog.Any("error", err),
+			)
+			return err
+		})(store)
+	}
+}
+
+// hashKey identifies a key in logs without revealing it, the same way as TieredCache does.
+func hashKey(key string) string {
+	if key == "" {
+		return ""
+	}
+	return fmt.Sprintf("%016x", xxhash.Sum64String(key))
+}
+
+// WithTimeout bounds every operation. Stores that ignore the context, such as bigcache, are not interrupted,
+// but the caller stops waiting for them once the timeout passes.
+func WithTimeout(timeout time.Duration) Middleware {
+	return Intercept(func(ctx context.Context, op Op, key string, next func(ctx context.Context) error) error {
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		done := make(chan error, 1)
+		go func() {
+			done <- next(ctx)
+		}()
+
+		select {
+		case err := <-done:
+			return err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+}
+
+type RetryConfig struct {
+	// Attempts is the total number of tries, defaults to 3.
+	Attempts int
+	// Backoff is the wait before the first retry, doubled for every further retry. Defaults to 10ms.
+	Backoff time.Duration
+	// MaxBackoff caps the wait between retries, defaults to one second.
+	MaxBackoff time.Duration
+	// Retryable decides which errors are retried. By default everything but misses, corrupt entries and
+	// open circuit breakers is, including timeouts of a WithTimeout further down the chain.
+	// Nothing is retried once ctx is done.
+	Retryable func(err error) bool
+}
+
+// WithRetry retries failed operations with exponential backoff.
+func WithRetry(config RetryConfig) Middleware {
+	if config.Attempts <= 0 {
+		config.Attempts = 3
+	}
+	if config.Backoff <= 0 {
+		config.Backoff = 10 * time.Millisecond
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = time.Second
+	}
+	if config.Retryable == nil {
+		config.Retryable = retryable
+	}
+
+	return Intercept(func(ctx context.Context, op Op, key string, next func(ctx context.Context) error) error {
+		backoff := config.Backoff
+		for attempt := 1; ; attempt++ {
+			err := next(ctx)
+			if err == nil || attempt >= config.Attempts || ctx.Err() != nil || !config.Retryable(err) {
+				return err
+			}
+
+			select {
+			case <-ctx.Done():
+				return err
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, config.MaxBackoff)
+		}
+	})
+}
+
+func retryable(err error) bool {
+	return !errors.Is(err, interfaces.ErrNotFound) &&
+		!errors.Is(err, interfaces.ErrCorrupt) &&
+		!errors.Is(err, ErrCircuitOpen)
+}
+
+// WithReadOnly drops every write, so a shared store can be read without this process changing it.
+// Writes report success, as TieredCache stops at the first tier failing a write.
+func WithReadOnly() Middleware {
+	return Intercept(func(ctx context.Context, op Op, key string, next func(ctx context.Context) error) error {
+		if op.IsWrite() {
+			return nil
+		}
+		return next(ctx)
+	})
+}
@@ -0,0 +1,189 @@
+package stores
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/reksie/tieredcache/pkg/interfaces"
+	"github.com/stretchr/testify/assert"
+)
+
+// flakyStore fails the first failures operations, and sleeps delay in every Get.
+type flakyStore struct {
+	interfaces.CacheStore
+	failures atomic.Int32
+	calls    atomic.Int32
+	delay    time.Duration
+}
+
+func (f *flakyStore) Get(ctx context.Context, key string) (any, error) {
+	f.calls.Add(1)
+	time.Sleep(f.delay)
+	if f.failures.Add(-1) >= 0 {
+		return nil, errors.New("connection reset")
+	}
+	return f.CacheStore.Get(ctx, key)
+}
+
+func TestChainOrder(t *testing.T) {
+	ctx := context.Background()
+	var order []string
+	record := func(name string) Middleware {
+		return Intercept(func(ctx context.Context, op Op, key string, next func(ctx context.Context) error) error {
+			order = append(order, name)
+			return next(ctx)
+		})
+	}
+
+	store := Chain(CreateLocalStore("local", LocalStoreConfig{}), record("outer"), record("inner"))
+	assert.Equal(t, "local", store.Name())
+
+	assert.NoError(t, store.Set(ctx, "key1", "value1", time.Minute))
+	assert.Equal(t, []string{"outer", "inner"}, order)
+}
+
+func TestChainPreservesOptionalInterfaces(t *testing.T) {
+	ctx := context.Background()
+
+	var evicted []string
+	local := Chain(CreateLocalStore("local", LocalStoreConfig{MaxEntries: 1}), WithTimeout(time.Second), WithRetry(RetryConfig{}))
+	local.(interfaces.EvictionNotifier).OnEvict(func(key string) {
+		evicted = append(evicted, key)
+	})
+
+	assert.NoError(t, local.Set(ctx, "key1", "value1", time.Minute))
+	value, ttl, err := local.(interfaces.TTLStore).GetWithTTL(ctx, "key1")
+	assert.NoError(t, err)
+	assert.Equal(t, "value1", value)
+	assert.Greater(t, ttl, time.Duration(0))
+
+	assert.NoError(t, local.Set(ctx, "key2", "value2", time.Minute))
+	assert.Equal(t, []string{"key1"}, evicted)
+	stats, err := local.(interfaces.StatsStore).Stats(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), stats.Entries)
+	assert.Equal(t, uint64(1), stats.Evictions)
+
+	// The local store has no batch operations, they run key by key
+	assert.NoError(t, local.(interfaces.BatchStore).SetMany(ctx, map[string]any{"key3": "value3"}, time.Minute))
+	values, err := local.(interfaces.BatchStore).GetMany(ctx, []string{"key1", "key3"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"key3": "value3"}, values)
+
+	redis := Chain(CreateRedisStore("redis", redisClient, RedisStoreConfig{UseNativeTTL: true}), WithReadOnly(), WithLogging(nil))
+	_, err = redis.(interfaces.StatsStore).Stats(ctx)
+	assert.NoError(t, err)
+
+	// Decorators forward as well, whatever their position in the chain
+	decorated := Chain(CreateChecksumStore(CreateCompressedStore(CreateLocalStore("decorated", LocalStoreConfig{}), CompressedStoreConfig{}), ChecksumStoreConfig{}), WithLogging(nil))
+	assert.NoError(t, decorated.Set(ctx, "key1", "value1", time.Minute))
+	value, ttl, err = decorated.(interfaces.TTLStore).GetWithTTL(ctx, "key1")
+	assert.NoError(t, err)
+	assert.Equal(t, "value1", value)
+	assert.Greater(t, ttl, time.Duration(0))
+	stats, err = decorated.(interfaces.StatsStore).Stats(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), stats.Entries)
+
+	cache, err := createTestCache()
+	assert.NoError(t, err)
+	memory := Chain(CreateMemoryStore("memory", cache), WithReadOnly())
+	_, err = memory.(interfaces.StatsStore).Stats(ctx)
+	assert.NoError(t, err)
+	_, ttl, err = memory.(interfaces.TTLStore).GetWithTTL(ctx, "missing")
+	assert.ErrorIs(t, err, interfaces.ErrNotFound)
+	assert.Equal(t, time.Duration(0), ttl)
+
+	bare := Chain(&flakyStore{CacheStore: CreateLocalStore("bare", LocalStoreConfig{})}, WithReadOnly())
+	_, err = bare.(interfaces.StatsStore).Stats(ctx)
+	assert.ErrorIs(t, err, errors.ErrUnsupported)
+}
+
+func TestWithRetry(t *testing.T) {
+	ctx := context.Background()
+	inner := &flakyStore{CacheStore: CreateLocalStore("local", LocalStoreConfig{})}
+	store := Chain(inner, WithRetry(RetryConfig{Attempts: 3, Backoff: time.Millisecond}))
+	assert.NoError(t, store.Set(ctx, "key1", "value1", time.Minute))
+
+	inner.failures.Store(2)
+	value, err := store.Get(ctx, "key1")
+	assert.NoError(t, err)
+	assert.Equal(t, "value1", value)
+	assert.Equal(t, int32(3), inner.calls.Load())
+
+	inner.calls.Store(0)
+	inner.failures.Store(3)
+	_, err = store.Get(ctx, "key1")
+	assert.Error(t, err)
+	assert.Equal(t, int32(3), inner.calls.Load())
+
+	// Misses are not retried
+	inner.calls.Store(0)
+	_, err = store.Get(ctx, "non_existent_key")
+	assert.ErrorIs(t, err, interfaces.ErrNotFound)
+	assert.Equal(t, int32(1), inner.calls.Load())
+}
+
+func TestWithTimeout(t *testing.T) {
+	ctx := context.Background()
+	inner := &flakyStore{CacheStore: CreateLocalStore("local", LocalStoreConfig{}), delay: 50 * time.Millisecond}
+	store := Chain(inner, WithTimeout(5*time.Millisecond))
+
+	start := time.Now()
+	_, err := store.Get(ctx, "key1")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 40*time.Millisecond)
+
+	// Each retry gets a fresh timeout
+	inner.calls.Store(0)
+	store = Chain(inner, WithRetry(RetryConfig{Attempts: 2, Backoff: time.Millisecond}), WithTimeout(5*time.Millisecond))
+	_, err = store.Get(ctx, "key1")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, int32(2), inner.calls.Load())
+}
+
+func TestWithReadOnly(t *testing.T) {
+	ctx := context.Background()
+	inner := CreateLocalStore("local", LocalStoreConfig{})
+	store := Chain(inner, WithReadOnly())
+
+	assert.NoError(t, inner.Set(ctx, "key1", "value1", time.Minute))
+
+	assert.NoError(t, store.Set(ctx, "key2", "value2", time.Minute))
+	assert.NoError(t, store.Delete(ctx, "key1"))
+	assert.NoError(t, store.Clear(ctx))
+
+	value, err := store.Get(ctx, "key1")
+	assert.NoError(t, err)
+	assert.Equal(t, "value1", value)
+	_, err = inner.Get(ctx, "key2")
+	assert.Error(t, err)
+}
+
+func TestWithLogging(t *testing.T) {
+	ctx := context.Background()
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelInfo}))
+
+	inner := &flakyStore{CacheStore: CreateLocalStore("local", LocalStoreConfig{})}
+	store := Chain(inner, WithLogging(logger))
+
+	// Misses are only logged at debug level
+	_, err := store.Get(ctx, "key1")
+	assert.Error(t, err)
+	assert.Empty(t, buf.String())
+
+	inner.failures.Store(1)
+	_, err = store.Get(ctx, "key1")
+	assert.Error(t, err)
+	assert.Contains(t, buf.String(), "level=WARN")
+	// Keys are hashed, as they may hold user data
+	assert.Contains(t, buf.String(), "store=local op=get key="+hashKey("key1"))
+	assert.NotContains(t, buf.String(), "key1")
+	assert.Contains(t, buf.String(), `error="connection reset"`)
+}
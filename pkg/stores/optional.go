@@ -0,0 +1,71 @@
+package stores
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/reksie/tieredcache/pkg/interfaces"
+)
+
+// Stores wrapping another one implement every optional interface and forward to the wrapped store at call
+// time, so the interfaces survive any depth of wrapping. These helpers fall back to the CacheStore
+// methods when the wrapped store does not implement them.
+
+// getWithTTL reads from a store, with the remaining TTL when the store knows it and 0 otherwise.
+func getWithTTL(ctx context.Context, store interfaces.CacheStore, key string) (any, time.Duration, error) {
+	if ttlStore, ok := store.(interfaces.TTLStore); ok {
+		return ttlStore.GetWithTTL(ctx, key)
+	}
+	value, err := store.Get(ctx, key)
+	return value, 0, err
+}
+
+// getMany reads the keys in one round trip when the store can, and one by one otherwise.
+func getMany(ctx context.Context, store interfaces.CacheStore, keys []string) (map[string]any, error) {
+	if batchStore, ok := store.(interfaces.BatchStore); ok {
+		return batchStore.GetMany(ctx, keys)
+	}
+
+	values := make(map[string]any, len(keys))
+	for _, key := range keys {
+		value, err := store.Get(ctx, key)
+		if errors.Is(err, interfaces.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		values[key] = value
+	}
+	return values, nil
+}
+
+// setMany writes the values in one round trip when the store can, and one by one otherwise.
+func setMany(ctx context.Context, store interfaces.CacheStore, values map[string]any, ttl time.Duration) error {
+	if batchStore, ok := store.(interfaces.BatchStore); ok {
+		return batchStore.SetMany(ctx, values, ttl)
+	}
+
+	for key, value := range values {
+		if err := store.Set(ctx, key, value, ttl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// storeStats asks a store about itself, failing with errors.ErrUnsupported when it cannot tell.
+func storeStats(ctx context.Context, store interfaces.CacheStore) (interfaces.StoreStats, error) {
+	if statsStore, ok := store.(interfaces.StatsStore); ok {
+		return statsStore.Stats(ctx)
+	}
+	return interfaces.StoreStats{}, errors.ErrUnsupported
+}
+
+// onEvict subscribes to the evictions of a store, stores that do not evict never call fn.
+func onEvict(store interfaces.CacheStore, fn func(key string)) {
+	if notifier, ok := store.(interfaces.EvictionNotifier); ok {
+		notifier.OnEvict(fn)
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -13,11 +15,21 @@ import (
 type RedisStoreConfig struct {
 	UseJSONMarshalling bool
 	UseIntegerForTTL   bool
+	// UseNativeTTL stores values encoded with Codec and without an expiry envelope, relying only on Redis' own TTL.
+	// Takes precedence over UseJSONMarshalling.
+	UseNativeTTL bool
+	// Codec encodes values when UseNativeTTL is set, defaults to JSONCodec.
+	Codec Codec
+	// MemorySamples is the number of random keys whose MEMORY USAGE Stats extrapolates to estimate Bytes,
+	// defaults to 20. Negative disables the estimate.
+	MemorySamples int
 }
 
+const defaultMemorySamples = 20
+
 type redisStore struct {
 	name   string
-	client *redis.Client
+	client redis.UniversalClient
 	config RedisStoreConfig
 }
 
@@ -26,7 +38,12 @@ type redisItem struct {
 	ExpiresAt interface{} `json:"expires_at"`
 }
 
-func CreateRedisStore(name string, client *redis.Client, config RedisStoreConfig) interfaces.CacheStore {
+// CreateRedisStore creates a store on any go-redis client: a *redis.Client, a *redis.ClusterClient,
+// a failover (Sentinel) client or one created with redis.NewUniversalClient.
+func CreateRedisStore(name string, client redis.UniversalClient, config RedisStoreConfig) interfaces.CacheStore {
+	if config.Codec == nil {
+		config.Codec = JSONCodec{}
+	}
 	return &redisStore{
 		name:   name,
 		client: client,
@@ -39,6 +56,19 @@ func (r *redisStore) Name() string {
 }
 
 func (r *redisStore) Set(ctx context.Context, key string, value any, ttl time.Duration) error {
+	data, err := r.encode(value, ttl)
+	if err != nil {
+		return err
+	}
+	return r.client.Set(ctx, key, data, ttl).Err()
+}
+
+// encode turns a value into what is written to Redis.
+func (r *redisStore) encode(value any, ttl time.Duration) (any, error) {
+	if r.config.UseNativeTTL {
+		return r.config.Codec.Marshal(value)
+	}
+
 	if r.config.UseJSONMarshalling {
 		expiresAt := time.Now().Add(ttl)
 		item := redisItem{
@@ -51,35 +81,72 @@ func (r *redisStore) Set(ctx context.Context, key string, value any, ttl time.Du
 			item.ExpiresAt = expiresAt
 		}
 
-		data, err := json.Marshal(item)
-		if err != nil {
-			return err
-		}
-
-		return r.client.Set(ctx, key, data, ttl).Err()
+		return json.Marshal(item)
 	}
 
 	// If not using JSON marshalling, use Redis's built-in TTL
-	return r.client.Set(ctx, key, value, ttl).Err()
+	return value, nil
 }
 
 func (r *redisStore) Get(ctx context.Context, key string) (any, error) {
 	data, err := r.client.Get(ctx, key).Bytes()
 	if err == redis.Nil {
-		return nil, errors.New("key not found in cache")
+		return nil, interfaces.ErrNotFound
 	} else if err != nil {
 		return nil, err
 	}
 
+	return r.decode(ctx, key, data)
+}
+
+// GetWithTTL reads a key and its remaining TTL in one round trip by pipelining GET and PTTL.
+func (r *redisStore) GetWithTTL(ctx context.Context, key string) (any, time.Duration, error) {
+	pipe := r.client.Pipeline()
+	getCmd := pipe.Get(ctx, key)
+	ttlCmd := pipe.PTTL(ctx, key)
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, 0, err
+	}
+
+	data, err := getCmd.Bytes()
+	if err == redis.Nil {
+		return nil, 0, interfaces.ErrNotFound
+	} else if err != nil {
+		return nil, 0, err
+	}
+
+	value, err := r.decode(ctx, key, data)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// PTTL is negative for keys without an expiry
+	ttl := ttlCmd.Val()
+	if ttl < 0 {
+		ttl = 0
+	}
+	return value, ttl, nil
+}
+
+// decode turns the raw bytes of a key into the value that was set.
+func (r *redisStore) decode(ctx context.Context, key string, data []byte) (any, error) {
+	if r.config.UseNativeTTL {
+		value, err := r.config.Codec.Unmarshal(data)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", interfaces.ErrCorrupt, err)
+		}
+		return value, nil
+	}
+
 	if !r.config.UseJSONMarshalling {
 		// If not using JSON marshalling, return the raw data
 		return string(data), nil
 	}
 
 	var item redisItem
-	err = json.Unmarshal(data, &item)
+	err := json.Unmarshal(data, &item)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%w: %v", interfaces.ErrCorrupt, err)
 	}
 
 	var expiresAt time.Time
@@ -94,7 +161,7 @@ func (r *redisStore) Get(ctx context.Context, key string) (any, error) {
 		if !ok {
 			return nil, errors.New("invalid expiration time format")
 		}
-		expiresAt, err = time.Parse(time.RFC3339, expiresAtStr)
+		expiresAt, err = time.Parse(time.RFC3339Nano, expiresAtStr)
 		if err != nil {
 			return nil, err
 		}
@@ -102,21 +169,180 @@ func (r *redisStore) Get(ctx context.Context, key string) (any, error) {
 
 	if time.Now().After(expiresAt) {
 		r.Delete(ctx, key) // Delete expired key
-		return nil, errors.New("key expired")
+		return nil, interfaces.ErrExpired
 	}
 
 	return item.Value, nil
 
 }
 
+// GetMany reads the keys with MGET. In cluster mode MGET only works on keys in the same hash slot,
+// so the keys are grouped per slot and the MGETs are pipelined.
+func (r *redisStore) GetMany(ctx context.Context, keys []string) (map[string]any, error) {
+	// MGET without keys is a syntax error
+	if len(keys) == 0 {
+		return map[string]any{}, nil
+	}
+
+	groups := [][]string{keys}
+	if _, ok := r.client.(*redis.ClusterClient); ok {
+		groups = groupBySlot(keys)
+	}
+
+	pipe := r.client.Pipeline()
+	cmds := make([]*redis.SliceCmd, len(groups))
+	for i, group := range groups {
+		cmds[i] = pipe.MGet(ctx, group...)
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	values := make(map[string]any, len(keys))
+	for i, cmd := range cmds {
+		for j, raw := range cmd.Val() {
+			data, ok := raw.(string)
+			if !ok {
+				// nil for a missing key
+				continue
+			}
+			key := groups[i][j]
+			value, err := r.decode(ctx, key, []byte(data))
+			if err != nil {
+				continue
+			}
+			values[key] = value
+		}
+	}
+	return values, nil
+}
+
+// SetMany pipelines a SET per key, the cluster client routes each to the node owning its slot.
+func (r *redisStore) SetMany(ctx context.Context, values map[string]any, ttl time.Duration) error {
+	pipe := r.client.Pipeline()
+	for key, value := range values {
+		data, err := r.encode(value, ttl)
+		if err != nil {
+			return err
+		}
+		pipe.Set(ctx, key, data, ttl)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
 func (r *redisStore) Delete(ctx context.Context, key string) error {
 	return r.client.Del(ctx, key).Err()
 }
 
 func (r *redisStore) Clear(ctx context.Context) error {
+	// FLUSHDB on a cluster client only reaches one node, every master has to be flushed
+	if cluster, ok := r.client.(*redis.ClusterClient); ok {
+		return cluster.ForEachMaster(ctx, func(ctx context.Context, client *redis.Client) error {
+			return client.FlushDB(ctx).Err()
+		})
+	}
 	return r.client.FlushDB(ctx).Err()
 }
 
+// Stats implements interfaces.StatsStore. Entries is DBSIZE, so it counts every key of the database
+// and not only those of this store.
+func (r *redisStore) Stats(ctx context.Context) (interfaces.StoreStats, error) {
+	entries, err := r.client.DBSize(ctx).Result()
+	if err != nil {
+		return interfaces.StoreStats{}, err
+	}
+	stats := interfaces.StoreStats{Entries: entries}
+
+	samples := r.config.MemorySamples
+	if samples == 0 {
+		samples = defaultMemorySamples
+	}
+	if samples < 0 || entries == 0 {
+		return stats, nil
+	}
+
+	pipe := r.client.Pipeline()
+	randomKeys := make([]*redis.StringCmd, samples)
+	for i := range randomKeys {
+		randomKeys[i] = pipe.RandomKey(ctx)
+	}
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return stats, err
+	}
+
+	pipe = r.client.Pipeline()
+	usages := make([]*redis.Cmd, 0, samples)
+	for _, randomKey := range randomKeys {
+		if key, err := randomKey.Result(); err == nil {
+			// Spelled out rather than MemoryUsage, which sends a lowercase subcommand miniredis does not know
+			usages = append(usages, pipe.Do(ctx, "MEMORY", "USAGE", key))
+		}
+	}
+	if len(usages) == 0 {
+		return stats, nil
+	}
+	// Keys can expire or be deleted between the two round trips, those samples are skipped
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return stats, err
+	}
+
+	var total, sampled int64
+	for _, usage := range usages {
+		if bytes, err := usage.Int64(); err == nil {
+			total += bytes
+			sampled++
+		}
+	}
+	if sampled > 0 {
+		stats.Bytes = total * entries / sampled
+	}
+	return stats, nil
+}
+
 func (r *redisStore) Close() error {
 	return r.client.Close()
 }
+
+// groupBySlot groups keys by their Redis Cluster hash slot, keeping the order of first appearance.
+func groupBySlot(keys []string) [][]string {
+	var groups [][]string
+	indexes := make(map[uint16]int)
+	for _, key := range keys {
+		slot := redisClusterSlot(key)
+		index, found := indexes[slot]
+		if !found {
+			index = len(groups)
+			indexes[slot] = index
+			groups = append(groups, nil)
+		}
+		groups[index] = append(groups[index], key)
+	}
+	return groups
+}
+
+// redisClusterSlot returns the hash slot of a key, honouring {hash tags} as described in the Redis Cluster spec.
+func redisClusterSlot(key string) uint16 {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+	return crc16(key) % 16384
+}
+
+// crc16 is the CRC-16/XMODEM checksum used by Redis Cluster.
+func crc16(data string) uint16 {
+	var crc uint16
+	for i := 0; i < len(data); i++ {
+		crc ^= uint16(data[i]) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
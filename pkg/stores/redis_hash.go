@@ -0,0 +1,183 @@
+package stores
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/reksie/tieredcache/pkg/interfaces"
+)
+
+// RedisHashStore stores objects as Redis hashes, one field per top-level JSON field,
+// so a few fields of a large object can be read or updated without rewriting the whole object.
+type RedisHashStore interface {
+	interfaces.CacheStore
+
+	// SetFields updates some fields of an existing object, keeping its TTL.
+	// It fails when the object does not exist, rather than creating a partial object that never expires.
+	SetFields(ctx context.Context, key string, fields map[string]any) error
+
+	// GetFields reads some fields of an object, fields that do not exist are left out of the result.
+	GetFields(ctx context.Context, key string, fields ...string) (map[string]any, error)
+}
+
+type redisHashStore struct {
+	name   string
+	client redis.UniversalClient
+}
+
+// setFieldsScript only writes the fields when the hash exists, HSET alone would create it.
+var setFieldsScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 0 then
+	return 0
+end
+redis.call("HSET", KEYS[1], unpack(ARGV))
+return 1
+`)
+
+// CreateRedisHashStore creates a store keeping values in Redis hashes. Values must encode to a JSON object,
+// such as a struct or a map; Get returns the whole object as a map[string]any.
+func CreateRedisHashStore(name string, client redis.UniversalClient) RedisHashStore {
+	return &redisHashStore{
+		name:   name,
+		client: client,
+	}
+}
+
+func (r *redisHashStore) Name() string {
+	return r.name
+}
+
+// Set replaces the whole object atomically.
+func (r *redisHashStore) Set(ctx context.Context, key string, value any, ttl time.Duration) error {
+	fields, err := encodeHashFields(value)
+	if err != nil {
+		return err
+	}
+
+	pipe := r.client.TxPipeline()
+	pipe.Del(ctx, key)
+	if len(fields) > 0 {
+		pipe.HSet(ctx, key, fields)
+		if ttl > 0 {
+			pipe.PExpire(ctx, key, ttl)
+		}
+	}
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func (r *redisHashStore) Get(ctx context.Context, key string) (any, error) {
+	raw, err := r.client.HGetAll(ctx, key).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) == 0 {
+		return nil, interfaces.ErrNotFound
+	}
+
+	value := make(map[string]any, len(raw))
+	for field, data := range raw {
+		var fieldValue any
+		if err := json.Unmarshal([]byte(data), &fieldValue); err != nil {
+			return nil, fmt.Errorf("%w: field %s: %v", interfaces.ErrCorrupt, field, err)
+		}
+		value[field] = fieldValue
+	}
+	return value, nil
+}
+
+func (r *redisHashStore) SetFields(ctx context.Context, key string, fields map[string]any) error {
+	if len(fields) == 0 {
+		return nil
+	}
+
+	args := make([]any, 0, len(fields)*2)
+	for field, value := range fields {
+		data, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		args = append(args, field, data)
+	}
+
+	updated, err := setFieldsScript.Run(ctx, r.client, []string{key}, args...).Int()
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		return interfaces.ErrNotFound
+	}
+	return nil
+}
+
+func (r *redisHashStore) GetFields(ctx context.Context, key string, fields ...string) (map[string]any, error) {
+	// HMGET without fields is a syntax error
+	if len(fields) == 0 {
+		return map[string]any{}, nil
+	}
+
+	pipe := r.client.Pipeline()
+	existsCmd := pipe.Exists(ctx, key)
+	valuesCmd := pipe.HMGet(ctx, key, fields...)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	if existsCmd.Val() == 0 {
+		return nil, interfaces.ErrNotFound
+	}
+
+	values := make(map[string]any, len(fields))
+	for i, raw := range valuesCmd.Val() {
+		data, ok := raw.(string)
+		if !ok {
+			continue
+		}
+		var fieldValue any
+		if err := json.Unmarshal([]byte(data), &fieldValue); err != nil {
+			return nil, fmt.Errorf("%w: field %s: %v", interfaces.ErrCorrupt, fields[i], err)
+		}
+		values[fields[i]] = fieldValue
+	}
+	return values, nil
+}
+
+func (r *redisHashStore) Delete(ctx context.Context, key string) error {
+	return r.client.Del(ctx, key).Err()
+}
+
+func (r *redisHashStore) Clear(ctx context.Context) error {
+	if cluster, ok := r.client.(*redis.ClusterClient); ok {
+		return cluster.ForEachMaster(ctx, func(ctx context.Context, client *redis.Client) error {
+			return client.FlushDB(ctx).Err()
+		})
+	}
+	return r.client.FlushDB(ctx).Err()
+}
+
+func (r *redisHashStore) Close() error {
+	return r.client.Close()
+}
+
+// encodeHashFields splits a value into its top-level JSON fields, each encoded as JSON.
+func encodeHashFields(value any) (map[string]any, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, errors.New("value must encode to a JSON object")
+	}
+
+	fields := make(map[string]any, len(raw))
+	for field, fieldData := range raw {
+		fields[field] = []byte(fieldData)
+	}
+	return fields, nil
+}
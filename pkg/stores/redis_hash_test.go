@@ -0,0 +1,120 @@
+package stores
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/reksie/tieredcache/pkg/interfaces"
+	"github.com/stretchr/testify/assert"
+)
+
+type hashTestProfile struct {
+	Name    string   `json:"name"`
+	Age     int      `json:"age"`
+	Tags    []string `json:"tags"`
+	Private string   `json:"-"`
+}
+
+func TestRedisHashSetGet(t *testing.T) {
+	ctx := context.Background()
+	store := CreateRedisHashStore("hash_store", redisClient)
+	assert.Equal(t, "hash_store", store.Name())
+
+	profile := hashTestProfile{Name: "John", Age: 30, Tags: []string{"a", "b"}, Private: "secret"}
+	assert.NoError(t, store.Set(ctx, "hash:profile", profile, time.Minute))
+
+	// Each top-level field is its own hash field
+	fields, err := redisClient.HKeys(ctx, "hash:profile").Result()
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"name", "age", "tags"}, fields)
+
+	value, err := store.Get(ctx, "hash:profile")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"name": "John", "age": float64(30), "tags": []any{"a", "b"}}, value)
+
+	// Set replaces the whole object, fields missing from the new value are removed
+	assert.NoError(t, store.Set(ctx, "hash:profile", map[string]any{"name": "Jane"}, time.Minute))
+	value, err = store.Get(ctx, "hash:profile")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"name": "Jane"}, value)
+
+	_, err = store.Get(ctx, "hash:non_existent_key")
+	assert.Error(t, err)
+
+	assert.Error(t, store.Set(ctx, "hash:scalar", "not an object", time.Minute))
+}
+
+func TestRedisHashFields(t *testing.T) {
+	ctx := context.Background()
+	store := CreateRedisHashStore("hash_store", redisClient)
+
+	profile := hashTestProfile{Name: "John", Age: 30, Tags: []string{"a"}}
+	assert.NoError(t, store.Set(ctx, "hash:fields", profile, time.Minute))
+
+	assert.NoError(t, store.SetFields(ctx, "hash:fields", map[string]any{"age": 31, "email": "john@example.com"}))
+
+	values, err := store.GetFields(ctx, "hash:fields", "age", "email", "missing")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"age": float64(31), "email": "john@example.com"}, values)
+
+	values, err = store.GetFields(ctx, "hash:fields")
+	assert.NoError(t, err)
+	assert.Empty(t, values)
+
+	// Untouched fields keep their values
+	value, err := store.Get(ctx, "hash:fields")
+	assert.NoError(t, err)
+	assert.Equal(t, "John", value.(map[string]any)["name"])
+
+	// Partial updates never create an object
+	assert.Error(t, store.SetFields(ctx, "hash:fields_missing", map[string]any{"age": 1}))
+	exists, err := redisClient.Exists(ctx, "hash:fields_missing").Result()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), exists)
+
+	_, err = store.GetFields(ctx, "hash:fields_missing", "age")
+	assert.Error(t, err)
+}
+
+func TestRedisHashTTL(t *testing.T) {
+	ctx := context.Background()
+	store := CreateRedisHashStore("hash_store", redisClient)
+
+	assert.NoError(t, store.Set(ctx, "hash:ttl", map[string]any{"name": "John"}, 50*time.Millisecond))
+
+	// SetFields keeps the TTL of the hash
+	assert.NoError(t, store.SetFields(ctx, "hash:ttl", map[string]any{"age": 30}))
+	ttl, err := redisClient.PTTL(ctx, "hash:ttl").Result()
+	assert.NoError(t, err)
+	assert.Greater(t, ttl, time.Duration(0))
+
+	time.Sleep(100 * time.Millisecond)
+
+	_, err = store.Get(ctx, "hash:ttl")
+	assert.Error(t, err)
+}
+
+func TestRedisHashDelete(t *testing.T) {
+	ctx := context.Background()
+	store := CreateRedisHashStore("hash_store", redisClient)
+
+	assert.NoError(t, store.Set(ctx, "hash:delete", map[string]any{"name": "John"}, time.Minute))
+	assert.NoError(t, store.Delete(ctx, "hash:delete"))
+
+	_, err := store.Get(ctx, "hash:delete")
+	assert.Error(t, err)
+}
+
+func TestRedisHashCorruptField(t *testing.T) {
+	ctx := context.Background()
+	store := CreateRedisHashStore("hash_store", redisClient)
+
+	assert.NoError(t, store.Set(ctx, "hash:corrupt", map[string]any{"name": "John"}, time.Minute))
+	assert.NoError(t, redisClient.HSet(ctx, "hash:corrupt", "name", "{truncated").Err())
+
+	_, err := store.Get(ctx, "hash:corrupt")
+	assert.ErrorIs(t, err, interfaces.ErrCorrupt)
+	_, err = store.GetFields(ctx, "hash:corrupt", "name")
+	assert.ErrorIs(t, err, interfaces.ErrCorrupt)
+}
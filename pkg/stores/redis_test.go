@@ -9,50 +9,52 @@ import (
 	"testing"
 	"time"
 
+	"github.com/alicebob/miniredis/v2"
 	"github.com/go-test/deep"
 	"github.com/redis/go-redis/v9"
 	"github.com/reksie/tieredcache/pkg/interfaces"
+	"github.com/reksie/tieredcache/pkg/keys"
 	"github.com/stretchr/testify/assert"
-	"github.com/testcontainers/testcontainers-go"
-	"github.com/testcontainers/testcontainers-go/wait"
 )
 
 var (
 	redisClient *redis.Client
-	cleanup     func()
+	miniRedis   *miniredis.Miniredis
 )
 
+// localTest runs the tests against a real Redis on localhost:6379 instead of miniredis
 const localTest = false
 
 func TestMain(m *testing.M) {
 	// Setup
-	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
-	defer cancel()
-
 	log.Println("Setting up test environment...")
 
-	req := testcontainers.ContainerRequest{
-		Image:        "redis:latest",
-		ExposedPorts: []string{"6379/tcp"},
-		WaitingFor:   wait.ForLog("Ready to accept connections"),
-	}
-
-	redisContainer, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
-		ContainerRequest: req,
-		Started:          true,
-	})
-
-	if err != nil {
-		log.Fatalf("Failed to start Redis container: %v", err)
-	}
-
-	endpoint, err := redisContainer.Endpoint(ctx, "")
-	if err != nil {
-		log.Fatalf("Failed to get Redis endpoint: %v", err)
-	}
-
-	if localTest {
-		endpoint = "localhost:6379"
+	endpoint := "localhost:6379"
+	if !localTest {
+		var err error
+		miniRedis, err = miniredis.Run()
+		if err != nil {
+			log.Fatalf("Failed to start miniredis: %v", err)
+		}
+		endpoint = miniRedis.Addr()
+
+		// miniredis only expires keys when told to, so keep its clock in step with the real one
+		stop := make(chan struct{})
+		defer close(stop)
+		go func() {
+			ticker := time.NewTicker(time.Millisecond)
+			defer ticker.Stop()
+			last := time.Now()
+			for {
+				select {
+				case <-stop:
+					return
+				case now := <-ticker.C:
+					miniRedis.FastForward(now.Sub(last))
+					last = now
+				}
+			}
+		}()
 	}
 
 	log.Printf("Redis endpoint: %s", endpoint)
@@ -67,8 +69,8 @@ func TestMain(m *testing.M) {
 	// Teardown
 	log.Println("Cleaning up test environment...")
 	redisClient.Close()
-	if err := redisContainer.Terminate(ctx); err != nil {
-		log.Printf("Failed to terminate Redis container: %v", err)
+	if miniRedis != nil {
+		miniRedis.Close()
 	}
 
 	os.Exit(code)
@@ -273,6 +275,135 @@ func TestRedisSetGetWithoutJSONMarshalling(t *testing.T) {
 	assert.Equal(t, "value1", value)
 }
 
+func TestRedisNativeTTL(t *testing.T) {
+	ctx := context.Background()
+	store := CreateRedisStore("test_store", redisClient, RedisStoreConfig{UseNativeTTL: true})
+	assert.NoError(t, store.Clear(ctx))
+
+	err := store.Set(ctx, "key1", map[string]any{"name": "John", "age": 30}, 60*time.Second)
+	assert.NoError(t, err)
+
+	// Stored without an envelope
+	raw, err := redisClient.Get(ctx, "key1").Result()
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"name":"John","age":30}`, raw)
+
+	value, ttl, err := store.(interfaces.TTLStore).GetWithTTL(ctx, "key1")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"name": "John", "age": float64(30)}, value)
+	assert.InDelta(t, 60*time.Second, ttl, float64(time.Second))
+
+	err = store.Set(ctx, "key2", "value2", 5*time.Millisecond)
+	assert.NoError(t, err)
+	time.Sleep(20 * time.Millisecond)
+	_, _, err = store.(interfaces.TTLStore).GetWithTTL(ctx, "key2")
+	assert.Error(t, err)
+}
+
+func TestRedisGetWithTTLWithoutExpiry(t *testing.T) {
+	ctx := context.Background()
+	store := setupTest(t)
+
+	err := store.Set(ctx, "key1", "value1", 0)
+	assert.NoError(t, err)
+
+	value, ttl, err := store.(interfaces.TTLStore).GetWithTTL(ctx, "key1")
+	assert.NoError(t, err)
+	assert.Equal(t, "value1", value)
+	assert.Equal(t, time.Duration(0), ttl)
+}
+
+func TestRedisUniversalClient(t *testing.T) {
+	ctx := context.Background()
+	client := redis.NewUniversalClient(&redis.UniversalOptions{
+		Addrs: []string{redisClient.Options().Addr},
+	})
+	defer client.Close()
+
+	store := CreateRedisStore("test_store", client, RedisStoreConfig{UseJSONMarshalling: true})
+	assert.NoError(t, store.Clear(ctx))
+
+	err := store.Set(ctx, "key1", "value1", 60*time.Second)
+	assert.NoError(t, err)
+
+	value, err := store.Get(ctx, "key1")
+	assert.NoError(t, err)
+	assert.Equal(t, "value1", value)
+}
+
+func TestRedisClusterClient(t *testing.T) {
+	if localTest {
+		t.Skip("needs a cluster enabled Redis")
+	}
+
+	ctx := context.Background()
+	// miniredis answers CLUSTER SLOTS as a single node owning every slot
+	client := redis.NewClusterClient(&redis.ClusterOptions{
+		Addrs: []string{redisClient.Options().Addr},
+	})
+	defer client.Close()
+
+	store := CreateRedisStore("test_store", client, RedisStoreConfig{UseJSONMarshalling: true})
+	assert.NoError(t, store.Clear(ctx))
+
+	values := map[string]any{
+		keys.HashTag("user1", ":name"):  "John",
+		keys.HashTag("user1", ":email"): "john@example.com",
+		"unrelated":                     "value",
+	}
+	batch := store.(interfaces.BatchStore)
+	assert.NoError(t, batch.SetMany(ctx, values, 60*time.Second))
+
+	found, err := batch.GetMany(ctx, []string{keys.HashTag("user1", ":name"), keys.HashTag("user1", ":email"), "unrelated", "missing"})
+	assert.NoError(t, err)
+	assert.Equal(t, values, found)
+
+	found, err = batch.GetMany(ctx, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, found)
+
+	// Clear flushes every master
+	assert.NoError(t, store.Clear(ctx))
+	_, err = store.Get(ctx, "unrelated")
+	assert.Error(t, err)
+}
+
+func TestRedisClusterSlot(t *testing.T) {
+	// Values from the Redis Cluster specification and CLUSTER KEYSLOT
+	assert.Equal(t, uint16(12182), redisClusterSlot("foo"))
+	assert.Equal(t, uint16(11058), redisClusterSlot("somekey"))
+	assert.Equal(t, redisClusterSlot("user1000"), redisClusterSlot("{user1000}.following"))
+	assert.Equal(t, redisClusterSlot("{user1000}.following"), redisClusterSlot("{user1000}.followers"))
+	// An empty tag is not a tag, so the whole key is hashed
+	assert.Equal(t, crc16("foo{}{bar}")%16384, redisClusterSlot("foo{}{bar}"))
+
+	groups := groupBySlot([]string{"{a}1", "{b}1", "{a}2"})
+	assert.Equal(t, [][]string{{"{a}1", "{a}2"}, {"{b}1"}}, groups)
+}
+
+func TestRedisStats(t *testing.T) {
+	ctx := context.Background()
+	store := setupTest(t)
+
+	stats, err := store.(interfaces.StatsStore).Stats(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, interfaces.StoreStats{}, stats)
+
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, store.Set(ctx, fmt.Sprintf("key%d", i), "value", time.Minute))
+	}
+	stats, err = store.(interfaces.StatsStore).Stats(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10), stats.Entries)
+	assert.Greater(t, stats.Bytes, int64(0))
+
+	// The estimate can be turned off
+	noSamples := CreateRedisStore("test_store", redisClient, RedisStoreConfig{MemorySamples: -1})
+	stats, err = noSamples.(interfaces.StatsStore).Stats(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, interfaces.StoreStats{Entries: 10}, stats)
+}
+
 // func TestRedisClose(t *testing.T) {
 // 	store := setupTest(t)
 // 	err := store.Close()
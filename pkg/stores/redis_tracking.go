@@ -0,0 +1,309 @@
+package stores
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/reksie/tieredcache/pkg/interfaces"
+)
+
+// trackingChannel is where Redis publishes invalidations for clients redirecting them to a Pub/Sub connection.
+const trackingChannel = "__redis__:invalidate"
+
+// TrackingMode selects which keys Redis sends invalidations for.
+type TrackingMode int
+
+const (
+	// TrackingDefault only invalidates keys cached in the local store. Redis remembers the tracked keys,
+	// which costs server memory but keeps invalidation traffic to what is actually cached.
+	//
+	// The value being cached was read from Redis by another client, and Redis only starts tracking the key
+	// when this store reads it afterwards, so a write in between is never invalidated and the local entry
+	// stays stale until it expires. Use TrackingBroadcast, or short local TTLs, when that matters.
+	TrackingDefault TrackingMode = iota
+	// TrackingBroadcast invalidates every key matching the configured prefixes, whether cached or not.
+	// Redis keeps no per key state.
+	TrackingBroadcast
+)
+
+type TrackingStoreConfig struct {
+	Mode TrackingMode
+	// Prefixes limits broadcast mode to keys starting with one of them, all keys are broadcast when empty.
+	Prefixes []string
+	// PingInterval is how often the invalidation connection is checked when idle, defaults to five seconds.
+	PingInterval time.Duration
+	// ReconnectDelay is the wait between reconnect attempts, defaults to one second.
+	ReconnectDelay time.Duration
+}
+
+type trackingStore struct {
+	local  interfaces.CacheStore
+	config TrackingStoreConfig
+
+	// subscriber receives the invalidations, tracker is the connection tracking is enabled on
+	subscriber *redis.Client
+	tracker    *redis.Client
+	pubsub     *redis.PubSub
+
+	subscriberID atomic.Int64
+	// subscribed is true while the subscriber connection is set up to receive invalidations
+	subscribed atomic.Bool
+	// tracking is false while invalidations may be missed, the local store is not written to then
+	tracking atomic.Bool
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// CreateTrackingStore wraps a local store in front of Redis, using Redis 6+ server-assisted client side caching
+// (CLIENT TRACKING) to delete local entries when their keys change in Redis. The optional interfaces of the
+// local store, such as TTLStore, are forwarded to it.
+//
+// Invalidations are redirected to a dedicated Pub/Sub connection, and tracking is enabled on a second one.
+// When either connection is lost the invalidations sent meanwhile are lost too, and Redis forgets the keys
+// tracked on a closed connection, so the local store is cleared and not written to until tracking is
+// enabled again. A lost tracking connection is only noticed when it is next used or pinged, every
+// PingInterval, which bounds how long local entries can miss invalidations. The client is only used for
+// its options, the store opens its own connections. Redis Cluster is not supported, tracking state lives on each node.
+func CreateTrackingStore(local interfaces.CacheStore, client *redis.Client, config TrackingStoreConfig) interfaces.CacheStore {
+	if config.PingInterval <= 0 {
+		config.PingInterval = 5 * time.Second
+	}
+	if config.ReconnectDelay <= 0 {
+		config.ReconnectDelay = time.Second
+	}
+
+	t := &trackingStore{
+		local:  local,
+		config: config,
+	}
+
+	subscriberOptions := *client.Options()
+	// Redirected invalidations only arrive as Pub/Sub messages on RESP2 connections
+	subscriberOptions.Protocol = 2
+	subscriberOptions.OnConnect = func(ctx context.Context, cn *redis.Conn) error {
+		if onConnect := client.Options().OnConnect; onConnect != nil {
+			if err := onConnect(ctx, cn); err != nil {
+				return err
+			}
+		}
+		id, err := cn.ClientID(ctx).Result()
+		if err != nil {
+			return err
+		}
+		t.subscriberID.Store(id)
+		return nil
+	}
+	t.subscriber = redis.NewClient(&subscriberOptions)
+
+	trackerOptions := *client.Options()
+	// A single connection, so every tracked read happens where tracking is enabled
+	trackerOptions.PoolSize = 1
+	trackerOptions.MinIdleConns = 0
+	trackerOptions.OnConnect = func(ctx context.Context, cn *redis.Conn) error {
+		if onConnect := client.Options().OnConnect; onConnect != nil {
+			if err := onConnect(ctx, cn); err != nil {
+				return err
+			}
+		}
+		// A new tracking connection, whatever was tracked on the previous one is forgotten
+		t.tracking.Store(false)
+		if err := t.local.Clear(ctx); err != nil {
+			return err
+		}
+		if err := cn.Process(ctx, redis.NewCmd(ctx, t.trackingArgs()...)); err != nil {
+			return err
+		}
+		// Before the first subscription startTracking turns tracking on
+		if t.subscribed.Load() {
+			t.tracking.Store(true)
+		}
+		return nil
+	}
+	t.tracker = redis.NewClient(&trackerOptions)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.cancel = cancel
+	t.pubsub = t.subscriber.Subscribe(ctx, trackingChannel)
+	t.wg.Add(2)
+	go t.run(ctx)
+	go t.watchTracker(ctx)
+
+	return t
+}
+
+func (t *trackingStore) Name() string {
+	return t.local.Name()
+}
+
+func (t *trackingStore) Set(ctx context.Context, key string, value any, ttl time.Duration) error {
+	if !t.tracking.Load() {
+		return nil
+	}
+
+	if err := t.local.Set(ctx, key, value, ttl); err != nil {
+		return err
+	}
+
+	if t.config.Mode == TrackingDefault {
+		// Reading the key makes Redis track it. This happens after the local write, so an invalidation
+		// sent from now on finds the entry; writes before this read are missed, see TrackingDefault.
+		if err := t.tracker.Exists(ctx, key).Err(); err != nil {
+			t.local.Delete(ctx, key)
+			return err
+		}
+	}
+
+	if !t.tracking.Load() {
+		// Tracking was lost meanwhile, the entry may already be stale
+		return t.local.Delete(ctx, key)
+	}
+	return nil
+}
+
+func (t *trackingStore) Get(ctx context.Context, key string) (any, error) {
+	return t.local.Get(ctx, key)
+}
+
+func (t *trackingStore) Delete(ctx context.Context, key string) error {
+	return t.local.Delete(ctx, key)
+}
+
+func (t *trackingStore) Clear(ctx context.Context) error {
+	return t.local.Clear(ctx)
+}
+
+func (t *trackingStore) GetWithTTL(ctx context.Context, key string) (any, time.Duration, error) {
+	return getWithTTL(ctx, t.local, key)
+}
+
+func (t *trackingStore) Stats(ctx context.Context) (interfaces.StoreStats, error) {
+	return storeStats(ctx, t.local)
+}
+
+func (t *trackingStore) OnEvict(fn func(key string)) {
+	onEvict(t.local, fn)
+}
+
+func (t *trackingStore) Close() error {
+	t.cancel()
+	// Closing the Pub/Sub unblocks the receive loop
+	err := t.pubsub.Close()
+	t.wg.Wait()
+	t.tracking.Store(false)
+
+	return errors.Join(err, t.subscriber.Close(), t.tracker.Close(), t.local.Close())
+}
+
+func (t *trackingStore) run(ctx context.Context) {
+	defer t.wg.Done()
+
+	for ctx.Err() == nil {
+		msg, err := t.pubsub.ReceiveTimeout(ctx, t.config.PingInterval)
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			// Idle, make sure the connection is still there. A failed ping surfaces in the next receive.
+			t.pubsub.Ping(ctx)
+			continue
+		}
+		if err != nil {
+			t.lost(ctx)
+			continue
+		}
+
+		switch msg := msg.(type) {
+		case *redis.Subscription:
+			// Subscribed on a new connection, so tracking has to be pointed at it
+			if msg.Kind == "subscribe" {
+				if err := t.startTracking(ctx); err != nil {
+					t.lost(ctx)
+				}
+			}
+		case *redis.Message:
+			t.invalidate(ctx, msg)
+		}
+	}
+}
+
+// startTracking redirects tracking to the current subscriber connection.
+func (t *trackingStore) startTracking(ctx context.Context) error {
+	t.tracking.Store(false)
+	if err := t.local.Clear(ctx); err != nil {
+		return err
+	}
+
+	pipe := t.tracker.Pipeline()
+	pipe.Do(ctx, "CLIENT", "TRACKING", "OFF")
+	pipe.Do(ctx, t.trackingArgs()...)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return err
+	}
+
+	t.subscribed.Store(true)
+	t.tracking.Store(true)
+	return nil
+}
+
+// watchTracker pings the tracking connection, so a lost one is noticed and reconnected even without writes.
+func (t *trackingStore) watchTracker(ctx context.Context) {
+	defer t.wg.Done()
+
+	ticker := time.NewTicker(t.config.PingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := t.tracker.Ping(ctx).Err(); err != nil && ctx.Err() == nil {
+				// Nothing is tracked until it reconnects, which clears the local store again
+				t.tracking.Store(false)
+				t.local.Clear(ctx)
+			}
+		}
+	}
+}
+
+func (t *trackingStore) trackingArgs() []any {
+	args := []any{"CLIENT", "TRACKING", "ON", "REDIRECT", t.subscriberID.Load()}
+	if t.config.Mode == TrackingBroadcast {
+		args = append(args, "BCAST")
+		for _, prefix := range t.config.Prefixes {
+			args = append(args, "PREFIX", prefix)
+		}
+	}
+	return args
+}
+
+// lost clears the local store after the subscriber connection failed, since invalidations may have been missed.
+func (t *trackingStore) lost(ctx context.Context) {
+	t.subscribed.Store(false)
+	t.tracking.Store(false)
+	t.local.Clear(ctx)
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(t.config.ReconnectDelay):
+	}
+}
+
+func (t *trackingStore) invalidate(ctx context.Context, msg *redis.Message) {
+	keys := msg.PayloadSlice
+	if len(keys) == 0 && msg.Payload != "" {
+		keys = []string{msg.Payload}
+	}
+
+	// A null payload is sent when the whole database is flushed
+	if len(keys) == 0 {
+		t.local.Clear(ctx)
+		return
+	}
+	for _, key := range keys {
+		t.local.Delete(ctx, key)
+	}
+}
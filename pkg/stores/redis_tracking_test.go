@@ -0,0 +1,243 @@
+package stores
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/alicebob/miniredis/v2/server"
+	"github.com/redis/go-redis/v9"
+	"github.com/reksie/tieredcache/pkg/interfaces"
+	"github.com/stretchr/testify/assert"
+)
+
+// trackingRedis adds the CLIENT ID and CLIENT TRACKING commands miniredis lacks.
+// Invalidations are sent by publishing on the invalidation channel.
+type trackingRedis struct {
+	*miniredis.Miniredis
+
+	mu       sync.Mutex
+	ids      map[*server.Peer]int64
+	nextID   int64
+	tracking [][]string
+	// reads done by tracking connections, the keys Redis would track in default mode
+	trackedReads []string
+	// dropTracker closes the tracking connection at its next PING
+	dropTracker bool
+}
+
+func startTrackingRedis(t *testing.T) *trackingRedis {
+	mr := miniredis.RunT(t)
+	r := &trackingRedis{Miniredis: mr, ids: make(map[*server.Peer]int64)}
+	r.installHook()
+	return r
+}
+
+func (r *trackingRedis) installHook() {
+	r.Server().SetPreHook(func(peer *server.Peer, cmd string, args ...string) bool {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		if cmd == "EXISTS" && r.isTracking(peer) {
+			r.trackedReads = append(r.trackedReads, args...)
+		}
+		if cmd == "PING" && r.isTracking(peer) && r.dropTracker {
+			r.dropTracker = false
+			peer.Close()
+			return true
+		}
+		if cmd != "CLIENT" || len(args) == 0 {
+			return false
+		}
+
+		switch strings.ToUpper(args[0]) {
+		case "ID":
+			if _, ok := r.ids[peer]; !ok {
+				r.nextID++
+				r.ids[peer] = r.nextID
+			}
+			peer.WriteInt(int(r.ids[peer]))
+		case "TRACKING":
+			if strings.ToUpper(args[1]) == "ON" {
+				r.ids[peer] = -1
+				r.tracking = append(r.tracking, args[1:])
+			}
+			peer.WriteOK()
+		default:
+			return false
+		}
+		return true
+	})
+}
+
+func (r *trackingRedis) isTracking(peer *server.Peer) bool {
+	return r.ids[peer] == -1
+}
+
+func (r *trackingRedis) lastTracking() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.tracking) == 0 {
+		return nil
+	}
+	return r.tracking[len(r.tracking)-1]
+}
+
+// setupTrackingTest creates a tracking store on r, waiting for tracking to be enabled as the store does not cache before that.
+func setupTrackingTest(t *testing.T, r *trackingRedis, config TrackingStoreConfig) interfaces.CacheStore {
+	// Only its options are used
+	client := redis.NewClient(&redis.Options{Addr: r.Addr()})
+	defer client.Close()
+
+	config.ReconnectDelay = 10 * time.Millisecond
+	store := CreateTrackingStore(CreateLocalStore("local", LocalStoreConfig{}), client, config)
+	assert.Eventually(t, func() bool {
+		return store.(*trackingStore).tracking.Load()
+	}, time.Second, time.Millisecond)
+	return store
+}
+
+func TestTrackingDefaultMode(t *testing.T) {
+	ctx := context.Background()
+	r := startTrackingRedis(t)
+	store := setupTrackingTest(t, r, TrackingStoreConfig{})
+	defer store.Close()
+	assert.Equal(t, "local", store.Name())
+
+	assert.Equal(t, []string{"ON", "REDIRECT", "1"}, r.lastTracking())
+
+	assert.NoError(t, store.Set(ctx, "key1", "value1", time.Minute))
+	assert.NoError(t, store.Set(ctx, "key2", "value2", time.Minute))
+
+	r.mu.Lock()
+	assert.Equal(t, []string{"key1", "key2"}, r.trackedReads)
+	r.mu.Unlock()
+
+	r.Publish(trackingChannel, "key1")
+	assert.Eventually(t, func() bool {
+		_, err := store.Get(ctx, "key1")
+		return err != nil
+	}, time.Second, time.Millisecond)
+
+	value, err := store.Get(ctx, "key2")
+	assert.NoError(t, err)
+	assert.Equal(t, "value2", value)
+}
+
+func TestTrackingForwardsOptionalInterfaces(t *testing.T) {
+	ctx := context.Background()
+	r := startTrackingRedis(t)
+	store := setupTrackingTest(t, r, TrackingStoreConfig{})
+	defer store.Close()
+
+	assert.NoError(t, store.Set(ctx, "key1", "value1", time.Minute))
+
+	// TieredCache promotes hits with the TTL the local store has left
+	value, ttl, err := store.(interfaces.TTLStore).GetWithTTL(ctx, "key1")
+	assert.NoError(t, err)
+	assert.Equal(t, "value1", value)
+	assert.InDelta(t, time.Minute, ttl, float64(time.Second))
+
+	stats, err := store.(interfaces.StatsStore).Stats(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), stats.Entries)
+
+	_, ok := store.(interfaces.EvictionNotifier)
+	assert.True(t, ok)
+}
+
+func TestTrackingBroadcastMode(t *testing.T) {
+	ctx := context.Background()
+	r := startTrackingRedis(t)
+	store := setupTrackingTest(t, r, TrackingStoreConfig{
+		Mode:     TrackingBroadcast,
+		Prefixes: []string{"user:", "order:"},
+	})
+	defer store.Close()
+
+	assert.Equal(t, []string{"ON", "REDIRECT", "1", "BCAST", "PREFIX", "user:", "PREFIX", "order:"}, r.lastTracking())
+
+	assert.NoError(t, store.Set(ctx, "user:1", "value1", time.Minute))
+
+	// Broadcast mode needs no per key reads
+	r.mu.Lock()
+	assert.Empty(t, r.trackedReads)
+	r.mu.Unlock()
+
+	r.Publish(trackingChannel, "user:1")
+	assert.Eventually(t, func() bool {
+		_, err := store.Get(ctx, "user:1")
+		return err != nil
+	}, time.Second, time.Millisecond)
+}
+
+func TestTrackingConnectionLoss(t *testing.T) {
+	ctx := context.Background()
+	r := startTrackingRedis(t)
+	store := setupTrackingTest(t, r, TrackingStoreConfig{})
+	defer store.Close()
+	tracking := store.(*trackingStore)
+
+	assert.NoError(t, store.Set(ctx, "key1", "value1", time.Minute))
+
+	r.Close()
+
+	// Invalidations may be missed, so the local store is flushed and not written to
+	assert.Eventually(t, func() bool {
+		return !tracking.tracking.Load()
+	}, time.Second, time.Millisecond)
+	_, err := store.Get(ctx, "key1")
+	assert.Error(t, err)
+
+	assert.NoError(t, store.Set(ctx, "key2", "value2", time.Minute))
+	_, err = store.Get(ctx, "key2")
+	assert.Error(t, err)
+
+	assert.NoError(t, r.Restart())
+	r.installHook()
+
+	// Tracking is redirected to the new subscriber connection
+	assert.Eventually(t, tracking.tracking.Load, 2*time.Second, time.Millisecond)
+	assert.Equal(t, "REDIRECT", r.lastTracking()[1])
+
+	assert.NoError(t, store.Set(ctx, "key2", "value2", time.Minute))
+	value, err := store.Get(ctx, "key2")
+	assert.NoError(t, err)
+	assert.Equal(t, "value2", value)
+}
+
+func TestTrackingTrackerReconnect(t *testing.T) {
+	ctx := context.Background()
+	r := startTrackingRedis(t)
+	store := setupTrackingTest(t, r, TrackingStoreConfig{PingInterval: 10 * time.Millisecond})
+	defer store.Close()
+	tracking := store.(*trackingStore)
+
+	assert.NoError(t, store.Set(ctx, "key1", "value1", time.Minute))
+	r.mu.Lock()
+	enabled := len(r.tracking)
+	r.dropTracker = true
+	r.mu.Unlock()
+
+	// Redis forgot what the old connection tracked, so the local store is flushed
+	assert.Eventually(t, func() bool {
+		_, err := store.Get(ctx, "key1")
+		return err != nil
+	}, time.Second, time.Millisecond)
+
+	// Tracking is enabled again on the new connection
+	assert.Eventually(t, func() bool {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		return len(r.tracking) > enabled && tracking.tracking.Load()
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, []string{"ON", "REDIRECT", "1"}, r.lastTracking())
+
+	assert.NoError(t, store.Set(ctx, "key1", "value1", time.Minute))
+	value, err := store.Get(ctx, "key1")
+	assert.NoError(t, err)
+	assert.Equal(t, "value1", value)
+}
@@ -0,0 +1,112 @@
+package stores
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/dgraph-io/ristretto"
+	"github.com/reksie/tieredcache/pkg/interfaces"
+)
+
+type RistrettoStoreConfig struct {
+	// Cost returns the cost of a value, in the same unit as the cache's MaxCost.
+	// Defaults to EncodedSizeCost, so MaxCost can be expressed in bytes.
+	Cost func(value any) int64
+	// WaitForSet makes Set block until the value is visible to Get.
+	// Ristretto applies sets asynchronously, so without it a Get straight after a Set can miss.
+	WaitForSet bool
+}
+
+type ristrettoStore struct {
+	name   string
+	cache  *ristretto.Cache[string, any]
+	config RistrettoStoreConfig
+}
+
+// CreateRistrettoStore creates an in-memory store backed by ristretto.
+// Unlike CreateMemoryStore values are kept as Go values without serialization, expire using ristretto's
+// native TTLs and are admitted by its TinyLFU policy, so a Set may be dropped when the cache is full of hotter keys.
+func CreateRistrettoStore(name string, cache *ristretto.Cache[string, any], config RistrettoStoreConfig) interfaces.CacheStore {
+	if config.Cost == nil {
+		config.Cost = EncodedSizeCost
+	}
+	return &ristrettoStore{
+		name:   name,
+		cache:  cache,
+		config: config,
+	}
+}
+
+// EncodedSizeCost approximates the memory used by a value with the size of its JSON encoding.
+func EncodedSizeCost(value any) int64 {
+	switch v := value.(type) {
+	case []byte:
+		return int64(len(v))
+	case string:
+		return int64(len(v))
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil || len(data) == 0 {
+		return 1
+	}
+	return int64(len(data))
+}
+
+func (r *ristrettoStore) Name() string {
+	return r.name
+}
+
+func (r *ristrettoStore) Set(ctx context.Context, key string, value any, ttl time.Duration) error {
+	// ctx is ignored for ristretto
+	// A rejected set is not an error, the admission policy decided the value is not worth keeping
+	r.cache.SetWithTTL(key, value, r.config.Cost(value), ttl)
+	if r.config.WaitForSet {
+		r.cache.Wait()
+	}
+	return nil
+}
+
+func (r *ristrettoStore) Get(ctx context.Context, key string) (any, error) {
+	// ctx is ignored for ristretto
+	value, found := r.cache.Get(key)
+	if !found {
+		return nil, interfaces.ErrNotFound
+	}
+	return value, nil
+}
+
+func (r *ristrettoStore) Delete(ctx context.Context, key string) error {
+	// ctx is ignored for ristretto
+	r.cache.Del(key)
+	return nil
+}
+
+func (r *ristrettoStore) Clear(ctx context.Context) error {
+	// ctx is ignored for ristretto
+	r.cache.Clear()
+	return nil
+}
+
+// Stats implements interfaces.StatsStore from ristretto's metrics, so it reports nothing unless the
+// cache was created with Metrics enabled. Bytes is the total cost, which is bytes with the default Cost.
+// Ristretto counts deletes and expirations as evictions, so Evictions includes them.
+func (r *ristrettoStore) Stats(ctx context.Context) (interfaces.StoreStats, error) {
+	metrics := r.cache.Metrics
+	if metrics == nil {
+		return interfaces.StoreStats{}, nil
+	}
+	return interfaces.StoreStats{
+		Entries:   int64(metrics.KeysAdded() - metrics.KeysEvicted()),
+		Bytes:     int64(metrics.CostAdded() - metrics.CostEvicted()),
+		Hits:      metrics.Hits(),
+		Misses:    metrics.Misses(),
+		Evictions: metrics.KeysEvicted(),
+	}, nil
+}
+
+func (r *ristrettoStore) Close() error {
+	r.cache.Close()
+	return nil
+}
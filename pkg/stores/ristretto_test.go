@@ -0,0 +1,134 @@
+package stores
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/ristretto"
+	"github.com/reksie/tieredcache/pkg/interfaces"
+	"github.com/stretchr/testify/assert"
+)
+
+func createRistrettoTestCache(maxCost int64) (*ristretto.Cache[string, any], error) {
+	return ristretto.NewCache(&ristretto.Config[string, any]{
+		NumCounters: 1000,
+		MaxCost:     maxCost,
+		BufferItems: 64,
+	})
+}
+
+func TestRistrettoSetGet(t *testing.T) {
+	ctx := context.Background()
+	cache, err := createRistrettoTestCache(1 << 20)
+	assert.NoError(t, err)
+	store := CreateRistrettoStore("ristretto", cache, RistrettoStoreConfig{WaitForSet: true})
+	defer store.Close()
+	assert.Equal(t, "ristretto", store.Name())
+
+	type TestPerson struct {
+		Name string
+	}
+	person := &TestPerson{Name: "John"}
+
+	err = store.Set(ctx, "key1", person, time.Minute)
+	assert.NoError(t, err)
+
+	value, err := store.Get(ctx, "key1")
+	assert.NoError(t, err)
+	// Values are stored without serialization, so the same pointer comes back
+	assert.Same(t, person, value)
+
+	_, err = store.Get(ctx, "non_existent_key")
+	assert.Error(t, err)
+}
+
+func TestRistrettoGetExpired(t *testing.T) {
+	ctx := context.Background()
+	cache, err := createRistrettoTestCache(1 << 20)
+	assert.NoError(t, err)
+	store := CreateRistrettoStore("ristretto", cache, RistrettoStoreConfig{WaitForSet: true})
+	defer store.Close()
+
+	err = store.Set(ctx, "key1", "value1", 10*time.Millisecond)
+	assert.NoError(t, err)
+
+	time.Sleep(20 * time.Millisecond)
+
+	_, err = store.Get(ctx, "key1")
+	assert.Error(t, err)
+}
+
+func TestRistrettoDeleteAndClear(t *testing.T) {
+	ctx := context.Background()
+	cache, err := createRistrettoTestCache(1 << 20)
+	assert.NoError(t, err)
+	store := CreateRistrettoStore("ristretto", cache, RistrettoStoreConfig{WaitForSet: true})
+	defer store.Close()
+
+	assert.NoError(t, store.Set(ctx, "key1", "value1", time.Minute))
+	assert.NoError(t, store.Set(ctx, "key2", "value2", time.Minute))
+
+	assert.NoError(t, store.Delete(ctx, "key1"))
+	_, err = store.Get(ctx, "key1")
+	assert.Error(t, err)
+
+	assert.NoError(t, store.Clear(ctx))
+	_, err = store.Get(ctx, "key2")
+	assert.Error(t, err)
+}
+
+func TestRistrettoCostAdmission(t *testing.T) {
+	ctx := context.Background()
+	cache, err := createRistrettoTestCache(10)
+	assert.NoError(t, err)
+	store := CreateRistrettoStore("ristretto", cache, RistrettoStoreConfig{WaitForSet: true})
+	defer store.Close()
+
+	// Costs more than the whole cache, so it can never be admitted
+	err = store.Set(ctx, "big", "this value is longer than ten bytes", time.Minute)
+	assert.NoError(t, err)
+
+	_, err = store.Get(ctx, "big")
+	assert.Error(t, err)
+}
+
+func TestEncodedSizeCost(t *testing.T) {
+	assert.Equal(t, int64(5), EncodedSizeCost("hello"))
+	assert.Equal(t, int64(3), EncodedSizeCost([]byte("abc")))
+	assert.Equal(t, int64(len(`{"a":1}`)), EncodedSizeCost(map[string]int{"a": 1}))
+	assert.Equal(t, int64(1), EncodedSizeCost(func() {}))
+}
+
+func TestRistrettoStats(t *testing.T) {
+	ctx := context.Background()
+	cache, err := ristretto.NewCache(&ristretto.Config[string, any]{
+		NumCounters: 1000,
+		MaxCost:     1 << 20,
+		BufferItems: 64,
+		Metrics:     true,
+		// Keeps the cost to the encoded size, without ristretto's per item overhead
+		IgnoreInternalCost: true,
+	})
+	assert.NoError(t, err)
+	store := CreateRistrettoStore("ristretto", cache, RistrettoStoreConfig{WaitForSet: true})
+	defer store.Close()
+
+	assert.NoError(t, store.Set(ctx, "key1", "value1", time.Minute))
+	assert.NoError(t, store.Set(ctx, "key2", "value2", time.Minute))
+	store.Get(ctx, "key1")
+	store.Get(ctx, "non_existent_key")
+
+	stats, err := store.(interfaces.StatsStore).Stats(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, interfaces.StoreStats{Entries: 2, Bytes: 12, Hits: 1, Misses: 1}, stats)
+
+	// Without metrics there is nothing to report
+	cache, err = createRistrettoTestCache(1 << 20)
+	assert.NoError(t, err)
+	withoutMetrics := CreateRistrettoStore("ristretto", cache, RistrettoStoreConfig{WaitForSet: true})
+	defer withoutMetrics.Close()
+	stats, err = withoutMetrics.(interfaces.StatsStore).Stats(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, interfaces.StoreStats{}, stats)
+}
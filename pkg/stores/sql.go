@@ -0,0 +1,220 @@
+package stores
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/reksie/tieredcache/pkg/interfaces"
+)
+
+// SQLDialect selects the SQL flavour used by the SQL store.
+type SQLDialect int
+
+const (
+	SQLite SQLDialect = iota
+	Postgres
+)
+
+type SQLStoreConfig struct {
+	Dialect SQLDialect
+	// Table is the name of the cache table, defaults to "tieredcache".
+	Table string
+	// Namespace scopes the keys of this store, so several stores (or services) can share a table
+	// and Clear only removes the entries of its own namespace.
+	Namespace string
+	// PurgeInterval periodically deletes expired rows, 0 disables purging.
+	// Expired rows are never returned by Get either way.
+	PurgeInterval time.Duration
+}
+
+type sqlStore struct {
+	name    string
+	db      *sql.DB
+	config  SQLStoreConfig
+	queries sqlQueries
+	stop    chan struct{}
+	once    sync.Once
+}
+
+type sqlQueries struct {
+	get    string
+	set    string
+	delete string
+	clear  string
+	purge  string
+}
+
+var sqlIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// CreateSQLStore creates a store that keeps entries in a table of db, for services that have a database but no Redis.
+// The table must exist, see CreateSQLTable. Close stops the purging but leaves db open, as it is usually shared.
+func CreateSQLStore(name string, db *sql.DB, config SQLStoreConfig) (interfaces.CacheStore, error) {
+	if config.Table == "" {
+		config.Table = "tieredcache"
+	}
+	if !sqlIdentifier.MatchString(config.Table) {
+		return nil, fmt.Errorf("stores: invalid SQL table name %q", config.Table)
+	}
+
+	store := &sqlStore{
+		name:    name,
+		db:      db,
+		config:  config,
+		queries: buildSQLQueries(config),
+		stop:    make(chan struct{}),
+	}
+
+	if config.PurgeInterval > 0 {
+		go store.purgeLoop(config.PurgeInterval)
+	}
+
+	return store, nil
+}
+
+// CreateSQLTable creates the cache table used by CreateSQLStore if it does not exist yet.
+func CreateSQLTable(ctx context.Context, db *sql.DB, config SQLStoreConfig) error {
+	if config.Table == "" {
+		config.Table = "tieredcache"
+	}
+	if !sqlIdentifier.MatchString(config.Table) {
+		return fmt.Errorf("stores: invalid SQL table name %q", config.Table)
+	}
+
+	blobType := "BLOB"
+	if config.Dialect == Postgres {
+		blobType = "BYTEA"
+	}
+
+	statements := []string{
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %[1]s (
+	namespace TEXT NOT NULL,
+	key TEXT NOT NULL,
+	value %[2]s NOT NULL,
+	expires_at BIGINT,
+	PRIMARY KEY (namespace, key)
+)`, config.Table, blobType),
+		fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %[1]s_expires_at ON %[1]s (expires_at)`, config.Table),
+	}
+
+	for _, statement := range statements {
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func buildSQLQueries(config SQLStoreConfig) sqlQueries {
+	// expires_at holds unix milliseconds, NULL never expires
+	queries := sqlQueries{
+		get:    `SELECT value FROM %[1]s WHERE namespace = ? AND key = ? AND (expires_at IS NULL OR expires_at > ?)`,
+		set:    `INSERT INTO %[1]s (namespace, key, value, expires_at) VALUES (?, ?, ?, ?) ON CONFLICT (namespace, key) DO UPDATE SET value = excluded.value, expires_at = excluded.expires_at`,
+		delete: `DELETE FROM %[1]s WHERE namespace = ? AND key = ?`,
+		clear:  `DELETE FROM %[1]s WHERE namespace = ?`,
+		purge:  `DELETE FROM %[1]s WHERE expires_at IS NOT NULL AND expires_at <= ?`,
+	}
+
+	for _, query := range []*string{&queries.get, &queries.set, &queries.delete, &queries.clear, &queries.purge} {
+		*query = fmt.Sprintf(*query, config.Table)
+		if config.Dialect == Postgres {
+			*query = postgresPlaceholders(*query)
+		}
+	}
+	return queries
+}
+
+// postgresPlaceholders rewrites ? placeholders into Postgres' $1, $2, ...
+func postgresPlaceholders(query string) string {
+	result := make([]byte, 0, len(query)+8)
+	n := 0
+	for i := 0; i < len(query); i++ {
+		if query[i] == '?' {
+			n++
+			result = append(result, fmt.Sprintf("$%d", n)...)
+			continue
+		}
+		result = append(result, query[i])
+	}
+	return string(result)
+}
+
+func (s *sqlStore) Name() string {
+	return s.name
+}
+
+func (s *sqlStore) Set(ctx context.Context, key string, value any, ttl time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	var expiresAt sql.NullInt64
+	if ttl > 0 {
+		expiresAt = sql.NullInt64{Int64: time.Now().Add(ttl).UnixMilli(), Valid: true}
+	}
+
+	_, err = s.db.ExecContext(ctx, s.queries.set, s.config.Namespace, key, data, expiresAt)
+	return err
+}
+
+func (s *sqlStore) Get(ctx context.Context, key string) (any, error) {
+	var data []byte
+	err := s.db.QueryRowContext(ctx, s.queries.get, s.config.Namespace, key, time.Now().UnixMilli()).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, interfaces.ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, fmt.Errorf("%w: %v", interfaces.ErrCorrupt, err)
+	}
+	return value, nil
+}
+
+func (s *sqlStore) Delete(ctx context.Context, key string) error {
+	_, err := s.db.ExecContext(ctx, s.queries.delete, s.config.Namespace, key)
+	return err
+}
+
+func (s *sqlStore) Clear(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, s.queries.clear, s.config.Namespace)
+	return err
+}
+
+func (s *sqlStore) Close() error {
+	s.once.Do(func() {
+		close(s.stop)
+	})
+	return nil
+}
+
+// Purge deletes all expired rows in the table, across namespaces.
+func (s *sqlStore) Purge(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, s.queries.purge, time.Now().UnixMilli())
+	return err
+}
+
+func (s *sqlStore) purgeLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), interval)
+			// A failed purge is retried on the next tick
+			s.Purge(ctx)
+			cancel()
+		}
+	}
+}
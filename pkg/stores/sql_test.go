@@ -0,0 +1,131 @@
+package stores
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/reksie/tieredcache/pkg/interfaces"
+	"github.com/stretchr/testify/assert"
+	_ "modernc.org/sqlite"
+)
+
+func createSQLTestDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "cache.db"))
+	assert.NoError(t, err)
+	// Avoid SQLITE_BUSY between the tests and the purge loop
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	assert.NoError(t, CreateSQLTable(context.Background(), db, SQLStoreConfig{}))
+	return db
+}
+
+func TestSQLSetGet(t *testing.T) {
+	ctx := context.Background()
+	store, err := CreateSQLStore("sql", createSQLTestDB(t), SQLStoreConfig{})
+	assert.NoError(t, err)
+	defer store.Close()
+	assert.Equal(t, "sql", store.Name())
+
+	assert.NoError(t, store.Set(ctx, "key1", map[string]any{"name": "John"}, time.Minute))
+
+	value, err := store.Get(ctx, "key1")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"name": "John"}, value)
+
+	// Set again upserts
+	assert.NoError(t, store.Set(ctx, "key1", "value2", 0))
+	value, err = store.Get(ctx, "key1")
+	assert.NoError(t, err)
+	assert.Equal(t, "value2", value)
+
+	_, err = store.Get(ctx, "non_existent_key")
+	assert.Error(t, err)
+}
+
+func TestSQLGetExpired(t *testing.T) {
+	ctx := context.Background()
+	store, err := CreateSQLStore("sql", createSQLTestDB(t), SQLStoreConfig{})
+	assert.NoError(t, err)
+	defer store.Close()
+
+	assert.NoError(t, store.Set(ctx, "key1", "value1", 5*time.Millisecond))
+	time.Sleep(10 * time.Millisecond)
+
+	_, err = store.Get(ctx, "key1")
+	assert.Error(t, err)
+}
+
+func TestSQLPurge(t *testing.T) {
+	ctx := context.Background()
+	db := createSQLTestDB(t)
+	store, err := CreateSQLStore("sql", db, SQLStoreConfig{PurgeInterval: 5 * time.Millisecond})
+	assert.NoError(t, err)
+	defer store.Close()
+
+	assert.NoError(t, store.Set(ctx, "expiring", "value1", 5*time.Millisecond))
+	assert.NoError(t, store.Set(ctx, "kept", "value2", time.Minute))
+	time.Sleep(50 * time.Millisecond)
+
+	var count int
+	assert.NoError(t, db.QueryRowContext(ctx, "SELECT COUNT(*) FROM tieredcache").Scan(&count))
+	assert.Equal(t, 1, count)
+}
+
+func TestSQLNamespaces(t *testing.T) {
+	ctx := context.Background()
+	db := createSQLTestDB(t)
+	first, err := CreateSQLStore("sql", db, SQLStoreConfig{Namespace: "first"})
+	assert.NoError(t, err)
+	defer first.Close()
+	second, err := CreateSQLStore("sql", db, SQLStoreConfig{Namespace: "second"})
+	assert.NoError(t, err)
+	defer second.Close()
+
+	assert.NoError(t, first.Set(ctx, "key1", "first", time.Minute))
+	assert.NoError(t, second.Set(ctx, "key1", "second", time.Minute))
+
+	value, err := second.Get(ctx, "key1")
+	assert.NoError(t, err)
+	assert.Equal(t, "second", value)
+
+	// Clear only removes the store's own namespace
+	assert.NoError(t, first.Clear(ctx))
+	_, err = first.Get(ctx, "key1")
+	assert.Error(t, err)
+	_, err = second.Get(ctx, "key1")
+	assert.NoError(t, err)
+
+	assert.NoError(t, second.Delete(ctx, "key1"))
+	_, err = second.Get(ctx, "key1")
+	assert.Error(t, err)
+}
+
+func TestSQLCorruptEntry(t *testing.T) {
+	ctx := context.Background()
+	db := createSQLTestDB(t)
+	store, err := CreateSQLStore("sql", db, SQLStoreConfig{})
+	assert.NoError(t, err)
+	defer store.Close()
+
+	assert.NoError(t, store.Set(ctx, "key1", "value1", time.Minute))
+	_, err = db.ExecContext(ctx, "UPDATE tieredcache SET value = ? WHERE key = ?", []byte("{truncated"), "key1")
+	assert.NoError(t, err)
+
+	_, err = store.Get(ctx, "key1")
+	assert.ErrorIs(t, err, interfaces.ErrCorrupt)
+}
+
+func TestSQLPostgresQueries(t *testing.T) {
+	queries := buildSQLQueries(SQLStoreConfig{Dialect: Postgres, Table: "cache"})
+	assert.Equal(t, "SELECT value FROM cache WHERE namespace = $1 AND key = $2 AND (expires_at IS NULL OR expires_at > $3)", queries.get)
+	assert.Contains(t, queries.set, "VALUES ($1, $2, $3, $4)")
+}
+
+func TestSQLInvalidTable(t *testing.T) {
+	_, err := CreateSQLStore("sql", nil, SQLStoreConfig{Table: "cache; DROP TABLE users"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,202 @@
+package tieredcache
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/reksie/tieredcache/pkg/interfaces"
+	"github.com/reksie/tieredcache/pkg/stores"
+)
+
+// EventType is what happened to a cache entry.
+type EventType int
+
+const (
+	// EventHit is a tier lookup that found the key.
+	EventHit EventType = iota
+	// EventMiss is a tier lookup that did not find the key.
+	EventMiss
+	// EventExpire is a tier lookup that found the key expired, it is sent instead of EventMiss.
+	EventExpire
+	// EventSet is a write to a tier by Set.
+	EventSet
+	// EventPromotion is a write of a value found in a slower tier to a faster one.
+	EventPromotion
+	// EventDelete is a delete from a tier.
+	EventDelete
+	// EventEvict is an entry removed by a tier to make room, reported by stores implementing
+	// interfaces.EvictionNotifier. It is not synchronous with any cache operation.
+	EventEvict
+	// EventRefreshStart is the start of a background refresh of a stale Swr entry.
+	EventRefreshStart
+	// EventRefreshFinish is the end of a background refresh, Err is set when it failed.
+	EventRefreshFinish
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventHit:
+		return "hit"
+	case EventMiss:
+		return "miss"
+	case EventExpire:
+		return "expire"
+	case EventSet:
+		return "set"
+	case EventPromotion:
+		return "promotion"
+	case EventDelete:
+		return "delete"
+	case EventEvict:
+		return "evict"
+	case EventRefreshStart:
+		return "refresh_start"
+	case EventRefreshFinish:
+		return "refresh_finish"
+	default:
+		return "unknown"
+	}
+}
+
+// Event describes something that happened to a cache entry.
+type Event struct {
+	Type EventType
+	// Store is the name of the tier, empty for refreshes which concern the whole cache.
+	Store string
+	// Key is the key in the stores, including the cache version.
+	Key string
+	// Size is the encoded size in bytes of the value for hits, sets and promotions, 0 otherwise.
+	Size int
+	// Duration is how long the operation took, 0 for evictions and the start of refreshes.
+	Duration time.Duration
+	// Err is the error of a failed operation.
+	Err  error
+	Time time.Time
+}
+
+// Observer receives cache events. Observers registered with WithObserver are called synchronously
+// on the goroutine of the operation, wrap slow ones with NewAsyncObserver.
+type Observer interface {
+	OnEvent(event Event)
+}
+
+// ObserverFunc adapts a function to Observer.
+type ObserverFunc func(event Event)
+
+func (f ObserverFunc) OnEvent(event Event) {
+	f(event)
+}
+
+// WithObserver registers an observer for the events of the cache, it can be given more than once.
+// Evictions are subscribed to on every store implementing interfaces.EvictionNotifier, including
+// middleware chains and decorators, which forward the subscription to the store they wrap.
+func WithObserver(observer Observer) Option {
+	return func(tc *TieredCache) {
+		tc.observers = append(tc.observers, observer)
+	}
+}
+
+// observing reports whether any observer is registered, so events and their sizes are only built when needed.
+func (tc *TieredCache) observing() bool {
+	return len(tc.observers) > 0
+}
+
+func (tc *TieredCache) emit(event Event) {
+	event.Time = time.Now()
+	for _, observer := range tc.observers {
+		observer.OnEvent(event)
+	}
+}
+
+// emitLookup reports the result of a lookup in one tier. Failures other than misses are misses with Err set.
+func (tc *TieredCache) emitLookup(store interfaces.CacheStore, key string, value any, err error, duration time.Duration) {
+	event := Event{Type: EventHit, Store: store.Name(), Key: key, Duration: duration}
+	switch {
+	case err == nil:
+		event.Size = int(stores.EncodedSizeCost(value))
+	case errors.Is(err, interfaces.ErrExpired):
+		event.Type = EventExpire
+	case errors.Is(err, interfaces.ErrNotFound):
+		event.Type = EventMiss
+	default:
+		event.Type = EventMiss
+		event.Err = err
+	}
+	tc.emit(event)
+}
+
+// watchEvictions reports the evictions of the stores that tell about them.
+func (tc *TieredCache) watchEvictions() {
+	for _, store := range tc.stores {
+		notifier, ok := store.(interfaces.EvictionNotifier)
+		if !ok {
+			continue
+		}
+		name := store.Name()
+		notifier.OnEvict(func(key string) {
+			tc.emit(Event{Type: EventEvict, Store: name, Key: key})
+		})
+	}
+}
+
+// AsyncObserver hands events to an observer on its own goroutine through a buffer, so cache
+// operations never wait for it. Events are dropped while the buffer is full.
+type AsyncObserver struct {
+	observer Observer
+	events   chan Event
+	dropped  atomic.Uint64
+	done     chan struct{}
+	once     sync.Once
+	mu       sync.RWMutex
+	closed   bool
+}
+
+// NewAsyncObserver starts dispatching to observer with room for bufferSize pending events.
+func NewAsyncObserver(observer Observer, bufferSize int) *AsyncObserver {
+	a := &AsyncObserver{
+		observer: observer,
+		events:   make(chan Event, bufferSize),
+		done:     make(chan struct{}),
+	}
+	go a.dispatch()
+	return a
+}
+
+func (a *AsyncObserver) dispatch() {
+	defer close(a.done)
+	for event := range a.events {
+		a.observer.OnEvent(event)
+	}
+}
+
+func (a *AsyncObserver) OnEvent(event Event) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.closed {
+		a.dropped.Add(1)
+		return
+	}
+	select {
+	case a.events <- event:
+	default:
+		a.dropped.Add(1)
+	}
+}
+
+// Dropped returns the number of events dropped because the buffer was full or the observer closed.
+func (a *AsyncObserver) Dropped() uint64 {
+	return a.dropped.Load()
+}
+
+// Close stops accepting events and waits until the pending ones are delivered.
+func (a *AsyncObserver) Close() {
+	a.once.Do(func() {
+		a.mu.Lock()
+		a.closed = true
+		close(a.events)
+		a.mu.Unlock()
+	})
+	<-a.done
+}
@@ -0,0 +1,140 @@
+package tieredcache
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/reksie/tieredcache/pkg/interfaces"
+	"github.com/reksie/tieredcache/pkg/stores"
+	"github.com/stretchr/testify/assert"
+)
+
+// eventLog keeps the events it observes, take summarizes them as "type store key".
+type eventLog struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (l *eventLog) OnEvent(event Event) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, event)
+}
+
+func (l *eventLog) take() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var summaries []string
+	for _, event := range l.events {
+		summaries = append(summaries, event.Type.String()+" "+event.Store+" "+event.Key)
+	}
+	l.events = nil
+	return summaries
+}
+
+func TestObserver(t *testing.T) {
+	log := &eventLog{}
+	var sizes []int
+	l1 := stores.CreateLocalStore("l1", stores.LocalStoreConfig{Shards: 1, MaxEntries: 1})
+	l2 := stores.CreateLocalStore("l2", stores.LocalStoreConfig{})
+	observedCache := NewTieredCache(time.Minute, []interfaces.CacheStore{l1, l2}, WithObserver(log),
+		WithObserver(ObserverFunc(func(event Event) {
+			if event.Type == EventHit {
+				sizes = append(sizes, event.Size)
+			}
+		})))
+
+	// A hit in a slower tier is promoted
+	assert.NoError(t, l2.Set(ctx, "a", "value", time.Minute))
+	_, err := observedCache.Get(ctx, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"miss l1 a", "hit l2 a", "promotion l1 a"}, log.take())
+	assert.Greater(t, sizes[0], 0)
+
+	// The bounded tier evicts the promoted entry to make room
+	assert.NoError(t, observedCache.Set(ctx, "b", CacheItem{Data: "value", Timestamp: time.Now()}, time.Minute))
+	assert.Equal(t, []string{"evict l1 a", "set l1 b", "set l2 b"}, log.take())
+
+	assert.NoError(t, observedCache.Delete(ctx, "b"))
+	assert.Equal(t, []string{"delete l1 b", "delete l2 b"}, log.take())
+
+	assert.NoError(t, l2.Set(ctx, "c", "value", time.Millisecond))
+	time.Sleep(5 * time.Millisecond)
+	_, err = observedCache.Get(ctx, "c")
+	assert.Error(t, err)
+	assert.Equal(t, []string{"miss l1 c", "expire l2 c"}, log.take())
+}
+
+func TestObserverEvictThroughMiddleware(t *testing.T) {
+	log := &eventLog{}
+	local := stores.CreateLocalStore("local", stores.LocalStoreConfig{MaxEntries: 1})
+	chained := stores.Chain(stores.CreateChecksumStore(local, stores.ChecksumStoreConfig{}), stores.WithRetry(stores.RetryConfig{}))
+	observedCache := NewTieredCache(time.Minute, []interfaces.CacheStore{chained}, WithObserver(log))
+
+	assert.NoError(t, observedCache.Set(ctx, "a", CacheItem{Data: "value", Timestamp: time.Now()}, time.Minute))
+	assert.NoError(t, observedCache.Set(ctx, "b", CacheItem{Data: "value", Timestamp: time.Now()}, time.Minute))
+	assert.Equal(t, []string{"set local a", "evict local a", "set local b"}, log.take())
+}
+
+func TestObserverRefresh(t *testing.T) {
+	log := &eventLog{}
+	observedCache := NewTieredCache(time.Minute, []interfaces.CacheStore{stores.CreateLocalStore("local", stores.LocalStoreConfig{})},
+		WithObserver(log))
+
+	opts := QueryOptions[string]{
+		Context:       ctx,
+		TieredCache:   observedCache,
+		QueryKey:      "observed_key",
+		QueryFunction: func() (string, error) { return "value", nil },
+		Fresh:         time.Millisecond,
+		TTL:           time.Minute,
+	}
+	_, err := Swr(opts)
+	assert.NoError(t, err)
+	time.Sleep(5 * time.Millisecond)
+	log.take()
+
+	opts.QueryFunction = func() (string, error) { return "", errors.New("origin down") }
+	_, err = Swr(opts)
+	assert.NoError(t, err)
+
+	var finish Event
+	assert.Eventually(t, func() bool {
+		log.mu.Lock()
+		defer log.mu.Unlock()
+		for _, event := range log.events {
+			if event.Type == EventRefreshFinish {
+				finish = event
+				return true
+			}
+		}
+		return false
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, []string{`hit local ["observed_key"]`, `refresh_start  ["observed_key"]`, `refresh_finish  ["observed_key"]`}, log.take())
+	assert.EqualError(t, finish.Err, "origin down")
+}
+
+func TestAsyncObserver(t *testing.T) {
+	release := make(chan struct{})
+	var delivered []EventType
+	async := NewAsyncObserver(ObserverFunc(func(event Event) {
+		<-release
+		delivered = append(delivered, event.Type)
+	}), 1)
+
+	// The first event is being delivered and the second waits in the buffer, so the third is dropped
+	async.OnEvent(Event{Type: EventHit})
+	assert.Eventually(t, func() bool { return len(async.events) == 0 }, time.Second, time.Millisecond)
+	async.OnEvent(Event{Type: EventMiss})
+	async.OnEvent(Event{Type: EventSet})
+	assert.Equal(t, uint64(1), async.Dropped())
+
+	close(release)
+	async.Close()
+	assert.Equal(t, []EventType{EventHit, EventMiss}, delivered)
+
+	async.OnEvent(Event{Type: EventDelete})
+	assert.Equal(t, uint64(2), async.Dropped())
+}
@@ -0,0 +1,104 @@
+package tieredcache
+
+import (
+	"container/list"
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	defaultErrorLogInterval = time.Minute
+	// maxLimitedKeys bounds the keys the error log limiter remembers, the one logged longest ago is forgotten beyond it.
+	maxLimitedKeys = 10000
+)
+
+// WithLogger logs tier errors, corrupt entries, background refresh failures and invalidations to logger.
+// A nil logger uses slog.Default(), which is also the default; to silence the cache pass a logger
+// whose handler discards, such as slog.New(slog.NewTextHandler(io.Discard, nil)).
+func WithLogger(logger *slog.Logger) Option {
+	return func(tc *TieredCache) {
+		if logger == nil {
+			logger = slog.Default()
+		}
+		tc.logger = logger
+	}
+}
+
+// WithErrorLogInterval logs the same error for a key at most once per interval, defaults to a minute.
+// The next log of that error counts the ones suppressed in between. 0 logs every error.
+func WithErrorLogInterval(interval time.Duration) Option {
+	return func(tc *TieredCache) {
+		tc.errorLogs.interval = interval
+	}
+}
+
+// logError logs an error about a key at warn level, unless it was logged for that key within the interval.
+// The key is logged as its hash, like in traces.
+func (tc *TieredCache) logError(ctx context.Context, msg string, key string, err error, attrs ...slog.Attr) {
+	if !tc.logger.Enabled(ctx, slog.LevelWarn) {
+		return
+	}
+	suppressed, ok := tc.errorLogs.allow(msg+"\x00"+key, time.Now())
+	if !ok {
+		return
+	}
+
+	attrs = append(attrs, slog.String("key_hash", hashKey(key)), slog.Any("error", err))
+	if suppressed > 0 {
+		attrs = append(attrs, slog.Int("suppressed", suppressed))
+	}
+	tc.logger.LogAttrs(ctx, slog.LevelWarn, msg, attrs...)
+}
+
+// logLimiter remembers when an error was last logged for each key, up to maxLimitedKeys.
+// A key it forgot may be logged again before its interval has passed.
+type logLimiter struct {
+	interval time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	// order holds the *limitedLog entries from the one logged longest ago to the latest
+	order list.List
+}
+
+type limitedLog struct {
+	id         string
+	logged     time.Time
+	suppressed int
+}
+
+// allow tells whether an error may be logged, and how many were suppressed since the last one.
+func (l *logLimiter) allow(id string, now time.Time) (int, bool) {
+	if l.interval <= 0 {
+		return 0, true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if element, ok := l.entries[id]; ok {
+		entry := element.Value.(*limitedLog)
+		if now.Sub(entry.logged) < l.interval {
+			entry.suppressed++
+			return 0, false
+		}
+		suppressed := entry.suppressed
+		entry.logged = now
+		entry.suppressed = 0
+		l.order.MoveToBack(element)
+		return suppressed, true
+	}
+
+	if l.entries == nil {
+		l.entries = make(map[string]*list.Element)
+	}
+	if len(l.entries) >= maxLimitedKeys {
+		oldest := l.order.Front()
+		l.order.Remove(oldest)
+		delete(l.entries, oldest.Value.(*limitedLog).id)
+	}
+	l.entries[id] = l.order.PushBack(&limitedLog{id: id, logged: now})
+	return 0, true
+}
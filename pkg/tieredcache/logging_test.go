@@ -0,0 +1,173 @@
+package tieredcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/reksie/tieredcache/pkg/interfaces"
+	"github.com/reksie/tieredcache/pkg/stores"
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingHandler keeps every record, flattened to its message and attributes.
+type recordingHandler struct {
+	mu      sync.Mutex
+	records []map[string]any
+}
+
+func (h *recordingHandler) Enabled(context.Context, slog.Level) bool { return true }
+func (h *recordingHandler) WithAttrs([]slog.Attr) slog.Handler       { return h }
+func (h *recordingHandler) WithGroup(string) slog.Handler            { return h }
+
+func (h *recordingHandler) Handle(_ context.Context, record slog.Record) error {
+	fields := map[string]any{"msg": record.Message, "level": record.Level}
+	record.Attrs(func(attr slog.Attr) bool {
+		fields[attr.Key] = attr.Value.Any()
+		return true
+	})
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.records = append(h.records, fields)
+	return nil
+}
+
+func (h *recordingHandler) messages(msg string) []map[string]any {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var records []map[string]any
+	for _, record := range h.records {
+		if record["msg"] == msg {
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+// failingStore fails every read.
+type failingStore struct {
+	interfaces.CacheStore
+}
+
+func (s *failingStore) Get(ctx context.Context, key string) (any, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestLogging(t *testing.T) {
+	handler := &recordingHandler{}
+	failing := &failingStore{CacheStore: stores.CreateLocalStore("failing", stores.LocalStoreConfig{})}
+	local := stores.CreateLocalStore("local", stores.LocalStoreConfig{})
+	loggedCache := NewTieredCache(time.Minute, []interfaces.CacheStore{failing, local},
+		WithLogger(slog.New(handler)), WithErrorLogInterval(50*time.Millisecond))
+
+	// Tier errors are logged once per interval for a key, misses are not logged
+	assert.NoError(t, local.Set(ctx, "logged_key", "value", time.Minute))
+	for i := 0; i < 3; i++ {
+		_, err := loggedCache.Get(ctx, "logged_key")
+		assert.NoError(t, err)
+	}
+	_, err := loggedCache.Get(ctx, "other_key")
+	assert.Error(t, err)
+
+	reads := handler.messages("cache tier read failed")
+	assert.Len(t, reads, 2)
+	assert.Equal(t, "failing", reads[0]["store"])
+	assert.Equal(t, hashKey("logged_key"), reads[0]["key_hash"])
+	assert.EqualError(t, reads[0]["error"].(error), "connection refused")
+	assert.Equal(t, hashKey("other_key"), reads[1]["key_hash"])
+
+	// Once the interval has passed, the suppressed errors are counted
+	time.Sleep(60 * time.Millisecond)
+	_, err = loggedCache.Get(ctx, "logged_key")
+	assert.NoError(t, err)
+	reads = handler.messages("cache tier read failed")
+	assert.Len(t, reads, 3)
+	assert.Equal(t, int64(2), reads[2]["suppressed"])
+
+	// Invalidations are logged at debug level
+	assert.NoError(t, loggedCache.Delete(ctx, "logged_key"))
+	invalidations := handler.messages("cache entry invalidated")
+	assert.Len(t, invalidations, 1)
+	assert.Equal(t, slog.LevelDebug, invalidations[0]["level"])
+}
+
+func TestLoggingRefreshFailures(t *testing.T) {
+	handler := &recordingHandler{}
+	loggedCache := NewTieredCache(time.Minute, []interfaces.CacheStore{stores.CreateLocalStore("local", stores.LocalStoreConfig{})},
+		WithLogger(slog.New(handler)))
+
+	opts := QueryOptions[string]{
+		Context:       ctx,
+		TieredCache:   loggedCache,
+		QueryKey:      "refresh_key",
+		QueryFunction: func() (string, error) { return "value", nil },
+		Fresh:         time.Millisecond,
+		TTL:           time.Minute,
+	}
+	_, err := Swr(opts)
+	assert.NoError(t, err)
+	time.Sleep(5 * time.Millisecond)
+
+	var refreshes sync.WaitGroup
+	refreshes.Add(3)
+	opts.QueryFunction = func() (string, error) {
+		defer refreshes.Done()
+		return "", errors.New("origin down")
+	}
+	for i := 0; i < 3; i++ {
+		result, err := Swr(opts)
+		assert.NoError(t, err)
+		assert.Equal(t, "value", result)
+	}
+	refreshes.Wait()
+
+	// Only the first failure is logged within the default interval
+	assert.Eventually(t, func() bool {
+		return len(handler.messages("background refresh failed")) == 1
+	}, time.Second, time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+	failures := handler.messages("background refresh failed")
+	assert.Len(t, failures, 1)
+	assert.Equal(t, slog.LevelWarn, failures[0]["level"])
+	assert.Equal(t, hashKey(`["refresh_key"]`), failures[0]["key_hash"])
+}
+
+func TestLogLimiter(t *testing.T) {
+	limiter := logLimiter{interval: time.Minute}
+	now := time.Now()
+
+	_, ok := limiter.allow("key1", now)
+	assert.True(t, ok)
+	_, ok = limiter.allow("key1", now.Add(time.Second))
+	assert.False(t, ok)
+	_, ok = limiter.allow("key2", now.Add(time.Second))
+	assert.True(t, ok)
+
+	suppressed, ok := limiter.allow("key1", now.Add(time.Minute))
+	assert.True(t, ok)
+	assert.Equal(t, 1, suppressed)
+
+	// Once full, the key logged longest ago is forgotten to make room
+	limiter = logLimiter{interval: time.Minute}
+	for i := 0; i < maxLimitedKeys+1; i++ {
+		_, ok = limiter.allow(fmt.Sprintf("key%d", i), now)
+		assert.True(t, ok)
+	}
+	assert.Len(t, limiter.entries, maxLimitedKeys)
+	assert.Equal(t, maxLimitedKeys, limiter.order.Len())
+	_, ok = limiter.allow("key1", now)
+	assert.False(t, ok)
+	_, ok = limiter.allow("key0", now)
+	assert.True(t, ok)
+
+	// Without an interval every error is logged
+	limiter = logLimiter{}
+	for i := 0; i < 3; i++ {
+		_, ok = limiter.allow("key1", now)
+		assert.True(t, ok)
+	}
+}
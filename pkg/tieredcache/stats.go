@@ -0,0 +1,134 @@
+package tieredcache
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+
+	"github.com/reksie/tieredcache/pkg/interfaces"
+)
+
+// Stats is a snapshot of the statistics of a TieredCache, meant for health endpoints.
+type Stats struct {
+	// Tiers are in lookup order.
+	Tiers          []TierStats
+	Swr            SwrStats
+	CorruptEntries uint64
+}
+
+// TierStats are the statistics of one tier.
+type TierStats struct {
+	Name string
+	// Operations made by this TieredCache. Misses include expired and corrupt entries, Errors are failed
+	// operations of any kind and Sets include promotions.
+	Hits    uint64
+	Misses  uint64
+	Errors  uint64
+	Sets    uint64
+	Deletes uint64
+	// Store is what the store reports about itself when it implements interfaces.StatsStore.
+	Store interfaces.StoreStats
+	// StoreErr is set when the store failed to report its statistics.
+	StoreErr error
+}
+
+// SwrStats count how Swr found queries in the cache and the background refreshes of stale ones.
+type SwrStats struct {
+	Fresh           uint64
+	Stale           uint64
+	Misses          uint64
+	Refreshes       uint64
+	RefreshFailures uint64
+}
+
+type tierCounters struct {
+	hits    atomic.Uint64
+	misses  atomic.Uint64
+	errors  atomic.Uint64
+	sets    atomic.Uint64
+	deletes atomic.Uint64
+}
+
+type swrCounters struct {
+	fresh           atomic.Uint64
+	stale           atomic.Uint64
+	misses          atomic.Uint64
+	refreshes       atomic.Uint64
+	refreshFailures atomic.Uint64
+}
+
+// countRead counts the result of a lookup in a tier.
+func (c *tierCounters) countRead(err error) {
+	switch {
+	case err == nil:
+		c.hits.Add(1)
+	case errors.Is(err, interfaces.ErrNotFound), errors.Is(err, interfaces.ErrCorrupt):
+		c.misses.Add(1)
+	default:
+		c.errors.Add(1)
+	}
+}
+
+func (c *tierCounters) countSet(err error) {
+	if err != nil {
+		c.errors.Add(1)
+		return
+	}
+	c.sets.Add(1)
+}
+
+func (c *tierCounters) countDelete(err error) {
+	if err != nil {
+		c.errors.Add(1)
+		return
+	}
+	c.deletes.Add(1)
+}
+
+func (c *swrCounters) countLookup(state cacheState) {
+	switch state {
+	case cacheFresh:
+		c.fresh.Add(1)
+	case cacheStale:
+		c.stale.Add(1)
+	default:
+		c.misses.Add(1)
+	}
+}
+
+// Stats returns the counters of the cache and asks every store implementing interfaces.StatsStore
+// about its contents, which for remote stores means a round trip each.
+func (tc *TieredCache) Stats(ctx context.Context) Stats {
+	stats := Stats{
+		Tiers: make([]TierStats, len(tc.stores)),
+		Swr: SwrStats{
+			Fresh:           tc.swrCounters.fresh.Load(),
+			Stale:           tc.swrCounters.stale.Load(),
+			Misses:          tc.swrCounters.misses.Load(),
+			Refreshes:       tc.swrCounters.refreshes.Load(),
+			RefreshFailures: tc.swrCounters.refreshFailures.Load(),
+		},
+		CorruptEntries: tc.corruptEntries.Load(),
+	}
+
+	for i, store := range tc.stores {
+		counters := &tc.tierCounters[i]
+		tier := TierStats{
+			Name:    store.Name(),
+			Hits:    counters.hits.Load(),
+			Misses:  counters.misses.Load(),
+			Errors:  counters.errors.Load(),
+			Sets:    counters.sets.Load(),
+			Deletes: counters.deletes.Load(),
+		}
+		if statsStore, ok := store.(interfaces.StatsStore); ok {
+			tier.Store, tier.StoreErr = statsStore.Stats(ctx)
+			if errors.Is(tier.StoreErr, errors.ErrUnsupported) {
+				// A wrapper around a store without statistics
+				tier.StoreErr = nil
+			}
+		}
+		stats.Tiers[i] = tier
+	}
+	return stats
+}
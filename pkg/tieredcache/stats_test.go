@@ -0,0 +1,92 @@
+package tieredcache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/allegro/bigcache/v3"
+	"github.com/reksie/tieredcache/pkg/interfaces"
+	"github.com/reksie/tieredcache/pkg/stores"
+	"github.com/stretchr/testify/assert"
+)
+
+// brokenStatsStore cannot report its statistics.
+type brokenStatsStore struct {
+	interfaces.CacheStore
+}
+
+func (s *brokenStatsStore) Stats(ctx context.Context) (interfaces.StoreStats, error) {
+	return interfaces.StoreStats{}, errors.New("connection refused")
+}
+
+func TestStats(t *testing.T) {
+	local := stores.CreateLocalStore("local", stores.LocalStoreConfig{Shards: 1, MaxEntries: 1})
+	bigcacheInstance, _ := bigcache.New(context.Background(), bigcache.DefaultConfig(10*time.Minute))
+	memory := stores.CreateMemoryStore("memory", bigcacheInstance)
+	broken := &brokenStatsStore{CacheStore: &failingStore{CacheStore: stores.CreateLocalStore("broken", stores.LocalStoreConfig{})}}
+	statsCache := NewTieredCache(time.Minute, []interfaces.CacheStore{local, memory, broken})
+	defer statsCache.Close()
+
+	opts := QueryOptions[string]{
+		Context:       ctx,
+		TieredCache:   statsCache,
+		QueryKey:      "stats_key",
+		QueryFunction: func() (string, error) { return "value", nil },
+		Fresh:         time.Minute,
+		TTL:           time.Minute,
+	}
+
+	// A miss in every tier, written to all of them, then a fresh hit
+	_, err := Swr(opts)
+	assert.NoError(t, err)
+	_, err = Swr(opts)
+	assert.NoError(t, err)
+
+	// Evicts the query from the local tier, so the next lookup finds it in memory and promotes it
+	opts.QueryKey = "other_key"
+	_, err = Swr(opts)
+	assert.NoError(t, err)
+	opts.QueryKey = "stats_key"
+	_, err = Swr(opts)
+	assert.NoError(t, err)
+
+	assert.NoError(t, statsCache.Delete(ctx, `["stats_key"]`))
+
+	stats := statsCache.Stats(ctx)
+	assert.Equal(t, SwrStats{Fresh: 2, Misses: 2}, stats.Swr)
+	assert.Len(t, stats.Tiers, 3)
+
+	localStats := stats.Tiers[0]
+	assert.Equal(t, "local", localStats.Name)
+	assert.Equal(t, [5]uint64{1, 3, 0, 3, 1}, [5]uint64{localStats.Hits, localStats.Misses, localStats.Errors, localStats.Sets, localStats.Deletes})
+	assert.Equal(t, int64(0), localStats.Store.Entries)
+	assert.Equal(t, uint64(2), localStats.Store.Evictions)
+
+	memoryStats := stats.Tiers[1]
+	assert.Equal(t, [5]uint64{1, 2, 0, 2, 1}, [5]uint64{memoryStats.Hits, memoryStats.Misses, memoryStats.Errors, memoryStats.Sets, memoryStats.Deletes})
+	assert.Equal(t, int64(1), memoryStats.Store.Entries)
+	assert.Greater(t, memoryStats.Store.Bytes, int64(0))
+	assert.NoError(t, memoryStats.StoreErr)
+
+	brokenStats := stats.Tiers[2]
+	assert.Equal(t, [5]uint64{0, 0, 2, 2, 1}, [5]uint64{brokenStats.Hits, brokenStats.Misses, brokenStats.Errors, brokenStats.Sets, brokenStats.Deletes})
+	assert.EqualError(t, brokenStats.StoreErr, "connection refused")
+}
+
+func TestStatsThroughMiddleware(t *testing.T) {
+	local := stores.Chain(stores.CreateLocalStore("local", stores.LocalStoreConfig{}), stores.WithLogging(nil))
+	// Only the CacheStore methods, so there is nothing to report
+	bare := stores.Chain(&failingStore{CacheStore: stores.CreateLocalStore("bare", stores.LocalStoreConfig{})}, stores.WithReadOnly())
+	statsCache := NewTieredCache(time.Minute, []interfaces.CacheStore{local, bare})
+	defer statsCache.Close()
+
+	assert.NoError(t, local.Set(ctx, "key", "value", time.Minute))
+
+	stats := statsCache.Stats(ctx)
+	assert.Equal(t, int64(1), stats.Tiers[0].Store.Entries)
+	assert.NoError(t, stats.Tiers[0].StoreErr)
+	assert.Equal(t, interfaces.StoreStats{}, stats.Tiers[1].Store)
+	assert.NoError(t, stats.Tiers[1].StoreErr)
+}
@@ -2,17 +2,25 @@ package tieredcache
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"sync/atomic"
 	"time"
 
 	"github.com/reksie/tieredcache/pkg/interfaces"
 	"github.com/reksie/tieredcache/pkg/keys"
+	"github.com/reksie/tieredcache/pkg/metrics"
+	"github.com/reksie/tieredcache/pkg/stores"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type QueryFunction[R any] func() (R, error)
 
+// MigrateFunction converts data cached under an older schema version into the current type.
+type MigrateFunction[R any] func(version int, data any) (R, error)
+
 type QueryOptions[R any] struct {
 	Context       context.Context
 	TieredCache   *TieredCache
@@ -20,6 +28,13 @@ type QueryOptions[R any] struct {
 	QueryFunction QueryFunction[R]
 	Fresh         time.Duration
 	TTL           time.Duration
+	// Version is the schema version of R. Cached entries with a different version are
+	// passed to Migrate, or treated as a miss when Migrate is nil.
+	Version int
+	Migrate MigrateFunction[R]
+	// RaceOriginAfter starts QueryFunction when the cache has not answered after this long, and returns
+	// whichever answers first. Disabled when 0.
+	RaceOriginAfter time.Duration
 }
 
 type QueryResult struct {
@@ -30,18 +45,122 @@ type QueryResult struct {
 type CacheItem struct {
 	Data      any       `json:"data"`
 	Timestamp time.Time `json:"timestamp"` // maybe more optimal to store as Unix timestamp
+	Version   int       `json:"version"`
 }
 
 type TieredCache struct {
 	stores       []interfaces.CacheStore
 	defaultFresh time.Duration
+	keyFunc      keys.HashFunc
+	cacheVersion string
+
+	corruptionPolicy CorruptionPolicy
+	corruptEntries   atomic.Uint64
+
+	tierTimeouts map[string]TierTimeouts
+	lookupBudget time.Duration
+
+	metrics metrics.Recorder
+	tracer  trace.Tracer
+
+	logger    *slog.Logger
+	errorLogs logLimiter
+
+	observers []Observer
+
+	tierCounters []tierCounters
+	swrCounters  swrCounters
+}
+
+// TierTimeouts bounds the operations on a single tier. A zero timeout means no limit.
+type TierTimeouts struct {
+	Read  time.Duration
+	Write time.Duration
+}
+
+// CorruptionPolicy decides what Get does with entries a store reports as interfaces.ErrCorrupt.
+// Either way the entry is counted and the lookup moves on to the next tier.
+type CorruptionPolicy int
+
+const (
+	// DeleteCorrupt deletes the entry from the store it was found in, so it is not decoded again on every read.
+	DeleteCorrupt CorruptionPolicy = iota
+	// KeepCorrupt leaves the entry in place until it expires or is overwritten.
+	KeepCorrupt
+)
+
+// Option configures optional behaviour of a TieredCache.
+type Option func(*TieredCache)
+
+// WithKeyFunc sets the function used to turn a QueryKey into a store key, it is passed the parts of the QueryKey.
+// By default the parts are joined with ":" and JSON encoded, so []any{"users", 42} becomes ["users:42:"],
+// which is readable but can get long; use a hashing function such as keys.HashKeyXXHash64
+// (optionally wrapped in keys.WithPrefix) to keep keys small. Changing it changes every key, so
+// entries cached with the previous function are missed.
+func WithKeyFunc(keyFunc keys.HashFunc) Option {
+	return func(tc *TieredCache) {
+		tc.keyFunc = keyFunc
+	}
+}
+
+// WithCacheVersion prefixes every key with version, bumping it rolls all keys
+// so entries written by older deployments are never read.
+func WithCacheVersion(version string) Option {
+	return func(tc *TieredCache) {
+		tc.cacheVersion = version
+	}
+}
+
+// WithCorruptionPolicy sets how corrupt entries are handled, defaults to DeleteCorrupt.
+func WithCorruptionPolicy(policy CorruptionPolicy) Option {
+	return func(tc *TieredCache) {
+		tc.corruptionPolicy = policy
+	}
 }
 
-func NewTieredCache(defaultFresh time.Duration, stores []interfaces.CacheStore) *TieredCache {
-	return &TieredCache{
+// WithTierTimeouts sets the timeouts of the store with the given name. Stores that ignore their
+// context, such as in-process stores, only check it before starting.
+func WithTierTimeouts(storeName string, timeouts TierTimeouts) Option {
+	return func(tc *TieredCache) {
+		if tc.tierTimeouts == nil {
+			tc.tierTimeouts = make(map[string]TierTimeouts)
+		}
+		tc.tierTimeouts[storeName] = timeouts
+	}
+}
+
+// WithLookupBudget bounds the time Get spends across all tiers. Once it is spent the remaining
+// tiers are skipped and the lookup is a miss, so Swr moves on to the QueryFunction.
+func WithLookupBudget(budget time.Duration) Option {
+	return func(tc *TieredCache) {
+		tc.lookupBudget = budget
+	}
+}
+
+// WithMetrics reports the operations on every tier, entry sizes, Swr lookups and background refreshes to recorder.
+func WithMetrics(recorder metrics.Recorder) Option {
+	return func(tc *TieredCache) {
+		tc.metrics = recorder
+	}
+}
+
+func NewTieredCache(defaultFresh time.Duration, stores []interfaces.CacheStore, opts ...Option) *TieredCache {
+	tc := &TieredCache{
 		stores:       stores,
 		defaultFresh: defaultFresh,
+		metrics:      metrics.Noop{},
+		tracer:       defaultTracer(),
+		logger:       slog.Default(),
+		errorLogs:    logLimiter{interval: defaultErrorLogInterval},
+		tierCounters: make([]tierCounters, len(stores)),
+	}
+	for _, opt := range opts {
+		opt(tc)
 	}
+	if tc.observing() {
+		tc.watchEvictions()
+	}
+	return tc
 }
 
 func (tc *TieredCache) Set(ctx context.Context, key string, value any, ttl time.Duration) error {
@@ -53,40 +172,170 @@ func (tc *TieredCache) Set(ctx context.Context, key string, value any, ttl time.
 	storeValue := map[string]interface{}{
 		"data":      cacheItem.Data,
 		"timestamp": cacheItem.Timestamp.Format(time.RFC3339Nano),
+		"version":   cacheItem.Version,
 	}
 
-	for _, store := range tc.stores {
-		if err := store.Set(ctx, key, storeValue, ttl); err != nil {
+	// Encoding the value just to measure it is only worth it when someone is listening
+	size := -1
+	if _, noop := tc.metrics.(metrics.Noop); !noop || tc.observing() {
+		size = int(stores.EncodedSizeCost(storeValue))
+	}
+
+	key = tc.versionedKey(key)
+	for i, store := range tc.stores {
+		if size >= 0 {
+			tc.metrics.EntrySize(store.Name(), size)
+		}
+		if err := tc.setTier(ctx, i, key, storeValue, ttl, EventSet, size); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
+// Get returns the value from the first store that has the key. When it is found in a slower tier and
+// that store reports the remaining TTL (see interfaces.TTLStore), the value is promoted to the faster tiers
+// with the same remaining TTL, so it never outlives the original.
 func (tc *TieredCache) Get(ctx context.Context, key string) (any, error) {
-	for _, store := range tc.stores {
-		if value, err := store.Get(ctx, key); err == nil {
-			return value, nil
+	key = tc.versionedKey(key)
+
+	lookupCtx := ctx
+	if tc.lookupBudget > 0 {
+		var cancel context.CancelFunc
+		lookupCtx, cancel = context.WithTimeout(ctx, tc.lookupBudget)
+		defer cancel()
+	}
+
+	for i, store := range tc.stores {
+		if lookupCtx.Err() != nil {
+			break
 		}
+
+		spanCtx, span := tc.startTierSpan(lookupCtx, store, key)
+		readCtx, cancel := tc.tierContext(spanCtx, store, false)
+		start := time.Now()
+		value, ttl, err := getWithTTL(readCtx, store, key)
+		duration := time.Since(start)
+		tc.tierCounters[i].countRead(err)
+		tc.metrics.StoreOperation(store.Name(), stores.OpGet, metrics.ResultOf(stores.OpGet, err), duration)
+		cancel()
+		endTierSpan(span, value, err)
+		if tc.observing() {
+			tc.emitLookup(store, key, value, err, duration)
+		}
+		if err != nil {
+			switch {
+			case errors.Is(err, interfaces.ErrCorrupt):
+				tc.corrupt(ctx, store, key)
+			case !errors.Is(err, interfaces.ErrNotFound):
+				tc.logError(ctx, "cache tier read failed", key, err, slog.String("store", store.Name()))
+			}
+			continue
+		}
+		if i > 0 && ttl > 0 {
+			// Promotion is best effort, the value was found either way. It is not bound by the lookup budget.
+			promoteCtx := context.WithoutCancel(ctx)
+			size := 0
+			if tc.observing() {
+				size = int(stores.EncodedSizeCost(value))
+			}
+			for tier := range tc.stores[:i] {
+				tc.setTier(promoteCtx, tier, key, value, ttl, EventPromotion, size)
+			}
+		}
+		return value, nil
 	}
 	return nil, errors.New("key not found in any store")
 }
 
+// tierContext applies the read or write timeout of a store.
+func (tc *TieredCache) tierContext(ctx context.Context, store interfaces.CacheStore, write bool) (context.Context, context.CancelFunc) {
+	timeouts := tc.tierTimeouts[store.Name()]
+	timeout := timeouts.Read
+	if write {
+		timeout = timeouts.Write
+	}
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// setTier writes to the tier at the given index, reporting it as an EventSet or EventPromotion of a value of the given size.
+func (tc *TieredCache) setTier(ctx context.Context, tier int, key string, value any, ttl time.Duration, event EventType, size int) error {
+	store := tc.stores[tier]
+	ctx, cancel := tc.tierContext(ctx, store, true)
+	defer cancel()
+
+	start := time.Now()
+	err := store.Set(ctx, key, value, ttl)
+	duration := time.Since(start)
+	tc.tierCounters[tier].countSet(err)
+	tc.metrics.StoreOperation(store.Name(), stores.OpSet, metrics.ResultOf(stores.OpSet, err), duration)
+	if tc.observing() {
+		tc.emit(Event{Type: event, Store: store.Name(), Key: key, Size: size, Duration: duration, Err: err})
+	}
+	if err != nil {
+		tc.logError(ctx, "cache tier write failed", key, err, slog.String("store", store.Name()))
+	}
+	return err
+}
+
+// corrupt counts a corrupt entry and applies the corruption policy.
+func (tc *TieredCache) corrupt(ctx context.Context, store interfaces.CacheStore, key string) {
+	tc.corruptEntries.Add(1)
+	tc.logError(ctx, "corrupt cache entry", key, interfaces.ErrCorrupt,
+		slog.String("store", store.Name()), slog.Bool("deleted", tc.corruptionPolicy == DeleteCorrupt))
+	if tc.corruptionPolicy == DeleteCorrupt {
+		// Best effort, the lookup falls through either way
+		store.Delete(ctx, key)
+	}
+}
+
+// CorruptEntries returns the number of corrupt entries Get has found.
+func (tc *TieredCache) CorruptEntries() uint64 {
+	return tc.corruptEntries.Load()
+}
+
+// getWithTTL reads from a store, with the remaining TTL when the store knows it and 0 otherwise.
+func getWithTTL(ctx context.Context, store interfaces.CacheStore, key string) (any, time.Duration, error) {
+	if ttlStore, ok := store.(interfaces.TTLStore); ok {
+		return ttlStore.GetWithTTL(ctx, key)
+	}
+	value, err := store.Get(ctx, key)
+	return value, 0, err
+}
+
 func (tc *TieredCache) Delete(ctx context.Context, key string) error {
-	for _, store := range tc.stores {
-		if err := store.Delete(ctx, key); err != nil {
+	key = tc.versionedKey(key)
+	for i, store := range tc.stores {
+		deleteCtx, cancel := tc.tierContext(ctx, store, true)
+		start := time.Now()
+		err := store.Delete(deleteCtx, key)
+		duration := time.Since(start)
+		tc.tierCounters[i].countDelete(err)
+		tc.metrics.StoreOperation(store.Name(), stores.OpDelete, metrics.ResultOf(stores.OpDelete, err), duration)
+		cancel()
+		if tc.observing() {
+			tc.emit(Event{Type: EventDelete, Store: store.Name(), Key: key, Duration: duration, Err: err})
+		}
+		if err != nil {
+			tc.logError(ctx, "cache invalidation failed", key, err, slog.String("store", store.Name()))
 			return err
 		}
 	}
+	tc.logger.LogAttrs(ctx, slog.LevelDebug, "cache entry invalidated", slog.String("key_hash", hashKey(key)))
 	return nil
 }
 
 func (tc *TieredCache) Clear(ctx context.Context) error {
 	for _, store := range tc.stores {
 		if err := store.Clear(ctx); err != nil {
+			tc.logger.LogAttrs(ctx, slog.LevelWarn, "cache clear failed", slog.String("store", store.Name()), slog.Any("error", err))
 			return err
 		}
 	}
+	tc.logger.LogAttrs(ctx, slog.LevelInfo, "cache cleared")
 	return nil
 }
 
@@ -102,7 +351,7 @@ func (tc *TieredCache) Close() error {
 func Swr[R any](opts QueryOptions[R]) (R, error) {
 	var zeroValue R
 
-	key, err := generateKey(opts.QueryKey)
+	key, err := opts.TieredCache.generateKey(opts.QueryKey)
 	if err != nil {
 		return zeroValue, err
 	}
@@ -111,75 +360,314 @@ func Swr[R any](opts QueryOptions[R]) (R, error) {
 		opts.Fresh = opts.TieredCache.defaultFresh
 	}
 
+	ctx, span := opts.TieredCache.tracer.Start(opts.Context, "tieredcache.swr")
+	if span.IsRecording() {
+		span.SetAttributes(keyHash(key))
+	}
+	opts.Context = ctx
+
+	var data R
+	if opts.RaceOriginAfter > 0 {
+		data, err = raceOrigin(opts, key)
+	} else {
+		var state cacheState
+		data, state, err = lookup(opts, key)
+		data, err = serve(opts, key, data, state, err)
+	}
+	endSpan(span, err)
+	return data, err
+}
+
+// cacheState is the outcome of looking a query up in the cache.
+type cacheState int
+
+const (
+	cacheMiss cacheState = iota
+	cacheFresh
+	cacheStale
+)
+
+var swrOutcomes = map[cacheState]metrics.SwrOutcome{
+	cacheMiss:  metrics.SwrMiss,
+	cacheFresh: metrics.SwrFresh,
+	cacheStale: metrics.SwrStale,
+}
+
+// lookup reads a query from the cache and tells whether it is missing, fresh or stale.
+func lookup[R any](opts QueryOptions[R], key string) (R, cacheState, error) {
+	data, state, err := readCache(opts, key)
+	opts.TieredCache.swrCounters.countLookup(state)
+	opts.TieredCache.metrics.SwrLookup(swrOutcomes[state])
+	trace.SpanFromContext(opts.Context).SetAttributes(attrSwrOutcome.String(string(swrOutcomes[state])))
+	return data, state, err
+}
+
+func readCache[R any](opts QueryOptions[R], key string) (R, cacheState, error) {
+	var zeroValue R
+
 	cachedData, err := opts.TieredCache.Get(opts.Context, key)
-	if err == nil && cachedData != nil {
-		cacheMap, ok := cachedData.(map[string]interface{})
-		if !ok {
-			return zeroValue, errors.New("invalid cache item format")
-		}
+	if err != nil || cachedData == nil {
+		return zeroValue, cacheMiss, nil
+	}
 
-		data, dataOk := cacheMap["data"]
-		timestampStr, timeOk := cacheMap["timestamp"].(string)
+	cacheMap, ok := cachedData.(map[string]interface{})
+	if !ok {
+		return zeroValue, cacheMiss, errors.New("invalid cache item format")
+	}
 
-		if !dataOk || !timeOk {
-			return zeroValue, errors.New("invalid cache item structure")
-		}
+	data, dataOk := cacheMap["data"]
+	timestampStr, timeOk := cacheMap["timestamp"].(string)
 
-		timestamp, err := time.Parse(time.RFC3339Nano, timestampStr)
-		if err != nil {
-			return zeroValue, fmt.Errorf("error parsing timestamp: %v", err)
+	if !dataOk || !timeOk {
+		return zeroValue, cacheMiss, errors.New("invalid cache item structure")
+	}
+
+	timestamp, err := time.Parse(time.RFC3339Nano, timestampStr)
+	if err != nil {
+		return zeroValue, cacheMiss, fmt.Errorf("error parsing timestamp: %v", err)
+	}
+
+	// Entries from another schema version, or that no longer decode into R, are treated as a miss
+	typedData, ok := decodeVersioned(opts, cacheMap["version"], data)
+	if !ok {
+		return zeroValue, cacheMiss, nil
+	}
+
+	if time.Since(timestamp) <= opts.Fresh {
+		return typedData, cacheFresh, nil
+	}
+	return typedData, cacheStale, nil
+}
+
+// serve answers from a lookup, refreshing stale entries in the background and fetching misses.
+func serve[R any](opts QueryOptions[R], key string, cachedData R, state cacheState, err error) (R, error) {
+	if err != nil {
+		var zeroValue R
+		return zeroValue, err
+	}
+
+	switch state {
+	case cacheFresh:
+		return cachedData, nil
+	case cacheStale:
+		go refresh(opts, key)
+		return cachedData, nil
+	default:
+		return fetch(opts, key)
+	}
+}
+
+// fetch runs the QueryFunction and caches its result.
+func fetch[R any](opts QueryOptions[R], key string) (R, error) {
+	newData, err := query(opts)
+	if err != nil {
+		var zeroValue R
+		return zeroValue, err
+	}
+
+	store(opts, key, newData)
+	return newData, nil
+}
+
+// refresh runs in the background after the request that found the entry stale has been answered.
+// Its span is a new trace linked to the request's span, and it is not cancelled with the request.
+func refresh[R any](opts QueryOptions[R], key string) {
+	tc := opts.TieredCache
+	ctx, span := tc.tracer.Start(context.WithoutCancel(opts.Context), "tieredcache.refresh",
+		trace.WithNewRoot(), trace.WithLinks(trace.LinkFromContext(opts.Context)))
+	if span.IsRecording() {
+		span.SetAttributes(keyHash(key))
+	}
+	opts.Context = ctx
+
+	if tc.observing() {
+		tc.emit(Event{Type: EventRefreshStart, Key: tc.versionedKey(key)})
+	}
+	start := time.Now()
+	newData, err := query(opts)
+	if tc.observing() {
+		tc.emit(Event{Type: EventRefreshFinish, Key: tc.versionedKey(key), Duration: time.Since(start), Err: err})
+	}
+	tc.swrCounters.refreshes.Add(1)
+	if err != nil {
+		tc.swrCounters.refreshFailures.Add(1)
+	}
+	tc.metrics.BackgroundRefresh(err)
+	if err == nil {
+		store(opts, key, newData)
+	} else {
+		tc.logError(ctx, "background refresh failed", key, err)
+	}
+	endSpan(span, err)
+}
+
+func store[R any](opts QueryOptions[R], key string, data R) {
+	cacheItem := CacheItem{Data: data, Timestamp: time.Now(), Version: opts.Version}
+	opts.TieredCache.Set(opts.Context, key, cacheItem, opts.TTL)
+}
+
+// raceOrigin looks a query up like Swr, but also starts the QueryFunction when the cache has not answered
+// after RaceOriginAfter. A cached value that arrives first is returned, while the QueryFunction goes on to
+// refresh the entry; otherwise the QueryFunction's result is. The cache is the fallback when it fails.
+func raceOrigin[R any](opts QueryOptions[R], key string) (R, error) {
+	type lookupResult struct {
+		data  R
+		state cacheState
+		err   error
+	}
+	type fetchResult struct {
+		data R
+		err  error
+	}
+
+	lookups := make(chan lookupResult, 1)
+	go func() {
+		data, state, err := lookup(opts, key)
+		lookups <- lookupResult{data, state, err}
+	}()
+
+	timer := time.NewTimer(opts.RaceOriginAfter)
+	defer timer.Stop()
+
+	select {
+	case cached := <-lookups:
+		return serve(opts, key, cached.data, cached.state, cached.err)
+	case <-timer.C:
+	}
+
+	// The result is handed over before it is cached, slow tiers are likely slow to write too
+	fetches := make(chan fetchResult, 1)
+	go func() {
+		data, err := query(opts)
+		fetches <- fetchResult{data, err}
+		if err == nil {
+			store(opts, key, data)
 		}
+	}()
 
-		typedData, ok := data.(R)
-		if !ok {
-			return zeroValue, errors.New("cannot convert cached data to required type")
+	select {
+	case cached := <-lookups:
+		if cached.err == nil && cached.state != cacheMiss {
+			return cached.data, nil
+		}
+		fetched := <-fetches
+		if fetched.err != nil {
+			var zeroValue R
+			return zeroValue, fetched.err
+		}
+		return fetched.data, nil
+	case fetched := <-fetches:
+		if fetched.err == nil {
+			return fetched.data, nil
 		}
+		cached := <-lookups
+		if cached.err == nil && cached.state != cacheMiss {
+			return cached.data, nil
+		}
+		var zeroValue R
+		return zeroValue, fetched.err
+	}
+}
 
-		age := time.Since(timestamp)
+// decodeVersioned converts cached data into R, migrating it when it was written with another version.
+func decodeVersioned[R any](opts QueryOptions[R], version any, data any) (R, bool) {
+	var zeroValue R
 
-		if age <= opts.Fresh {
-			return typedData, nil
-		}
+	cachedVersion, ok := toInt(version)
+	if !ok {
+		// Entries written before versioning was introduced have no version
+		cachedVersion = 0
+	}
 
-		go func() {
+	if cachedVersion != opts.Version {
+		if opts.Migrate == nil {
+			return zeroValue, false
+		}
+		migrated, err := opts.Migrate(cachedVersion, data)
+		if err != nil {
+			return zeroValue, false
+		}
+		return migrated, true
+	}
 
-			newData, err := opts.QueryFunction()
-			if err == nil {
-				opts.TieredCache.Set(opts.Context, key, CacheItem{Data: newData, Timestamp: time.Now()}, opts.TTL)
-			} else {
-				log.Printf("Swr: Background refresh failed for key: %s, error: %v", key, err)
-			}
-		}()
+	return decodeData[R](data)
+}
 
-		return typedData, nil
+// decodeData converts cached data into R. Stores that serialize to JSON hand back maps and
+// float64s rather than R, so those are converted with a JSON round-trip.
+func decodeData[R any](data any) (R, bool) {
+	if typedData, ok := data.(R); ok {
+		return typedData, true
 	}
 
-	newData, err := opts.QueryFunction()
+	var typedData R
+	jsonBytes, err := json.Marshal(data)
 	if err != nil {
-		return zeroValue, err
+		return typedData, false
+	}
+	if err := json.Unmarshal(jsonBytes, &typedData); err != nil {
+		return typedData, false
 	}
+	return typedData, true
+}
 
-	cacheItem := CacheItem{Data: newData, Timestamp: time.Now()}
-	opts.TieredCache.Set(opts.Context, key, cacheItem, opts.TTL)
+func toInt(value any) (int, bool) {
+	switch v := value.(type) {
+	case int:
+		return v, true
+	case int64:
+		return int(v), true
+	case float64:
+		return int(v), true
+	case json.Number:
+		i, err := v.Int64()
+		return int(i), err == nil
+	default:
+		return 0, false
+	}
+}
 
-	return newData, nil
+func (tc *TieredCache) versionedKey(key string) string {
+	if tc.cacheVersion == "" {
+		return key
+	}
+	return tc.cacheVersion + ":" + key
 }
 
-func generateKey(queryKey any) (string, error) {
+func (tc *TieredCache) generateKey(queryKey any) (string, error) {
+	if tc.keyFunc == nil {
+		return defaultKey(queryKey)
+	}
 
-	// keys.HashKeyMD5
-	hashFunction := keys.HashKeyJson
+	switch v := queryKey.(type) {
+	case string:
+		return tc.keyFunc(v)
+	case []any:
+		return tc.keyFunc(v...)
+	case []string:
+		parts := make([]any, len(v))
+		for i, item := range v {
+			parts[i] = item
+		}
+		return tc.keyFunc(parts...)
+	default:
+		return tc.keyFunc(queryKey)
+	}
+}
 
+// defaultKey is the key format TieredCache has always used, kept as the default so upgrading
+// does not miss every entry already cached.
+func defaultKey(queryKey any) (string, error) {
 	switch v := queryKey.(type) {
 	case string:
-		return hashFunction(v)
+		return keys.HashKeyJson(v)
 	case []any:
 		key := ""
 		for _, item := range v {
 			key += fmt.Sprintf("%v:", item)
 		}
-		return hashFunction(key)
+		return keys.HashKeyJson(key)
 	default:
-		return hashFunction(fmt.Sprintf("%v", queryKey))
+		return keys.HashKeyJson(fmt.Sprintf("%v", queryKey))
 	}
 }
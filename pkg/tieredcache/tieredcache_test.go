@@ -2,11 +2,16 @@ package tieredcache
 
 import (
 	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/allegro/bigcache/v3"
 	"github.com/reksie/tieredcache/pkg/interfaces"
+	"github.com/reksie/tieredcache/pkg/keys"
+	"github.com/reksie/tieredcache/pkg/metrics"
 	"github.com/reksie/tieredcache/pkg/stores"
 	"github.com/stretchr/testify/assert"
 )
@@ -33,6 +38,13 @@ func TestMain(m *testing.M) {
 	cache.Close()
 }
 
+// newMemoryCache creates a cache like the shared one for tests using fixed keys,
+// which the shared cache would still hold when the tests are run again with -count.
+func newMemoryCache() *TieredCache {
+	bigcacheInstance, _ := bigcache.New(context.Background(), bigcache.DefaultConfig(10*time.Minute))
+	return NewTieredCache(5*time.Second, []interfaces.CacheStore{stores.CreateMemoryStore("memory", bigcacheInstance)})
+}
+
 func TestBasicSetAndGet(t *testing.T) {
 	key := "test_key"
 	value := "test_value"
@@ -119,3 +131,480 @@ func TestSWR(t *testing.T) {
 
 	assert.Equal(t, 2, fetchCount)
 }
+
+func TestKeyFunc(t *testing.T) {
+	defaultCache := NewTieredCache(time.Second, nil)
+	key, err := defaultCache.generateKey([]any{"users", 42})
+	assert.NoError(t, err)
+	// The format used before WithKeyFunc, so upgrading keeps hitting entries already cached
+	assert.Equal(t, `["users:42:"]`, key)
+
+	hashedCache := NewTieredCache(time.Second, nil, WithKeyFunc(keys.WithPrefix(keys.HashKeyXXHash64)))
+	key, err = hashedCache.generateKey([]string{"users", "42"})
+	assert.NoError(t, err)
+
+	hash, _ := keys.HashKeyXXHash64("users", "42")
+	assert.Equal(t, "users:"+hash, key)
+}
+
+func TestSWRStructThroughJSONStore(t *testing.T) {
+	swrCache := newMemoryCache()
+	defer swrCache.Close()
+
+	type Person struct {
+		Name string `json:"name"`
+		Age  int    `json:"age"`
+	}
+
+	var fetchCount int
+	opts := QueryOptions[Person]{
+		Context:     ctx,
+		TieredCache: swrCache,
+		QueryKey:    []any{"swr_struct_key"},
+		QueryFunction: func() (Person, error) {
+			fetchCount++
+			return Person{Name: "John", Age: 30}, nil
+		},
+		Fresh: time.Minute,
+		TTL:   time.Minute,
+	}
+
+	result, err := Swr(opts)
+	assert.NoError(t, err)
+	assert.Equal(t, Person{Name: "John", Age: 30}, result)
+
+	// The memory store hands back a map, which should still decode into Person
+	result, err = Swr(opts)
+	assert.NoError(t, err)
+	assert.Equal(t, Person{Name: "John", Age: 30}, result)
+	assert.Equal(t, 1, fetchCount)
+}
+
+func TestSWRVersionMismatch(t *testing.T) {
+	swrCache := newMemoryCache()
+	defer swrCache.Close()
+
+	var fetchCount int
+	queryFn := func() (string, error) {
+		fetchCount++
+		return "fetched_value", nil
+	}
+
+	opts := QueryOptions[string]{
+		Context:       ctx,
+		TieredCache:   swrCache,
+		QueryKey:      []any{"swr_version_key"},
+		QueryFunction: queryFn,
+		Fresh:         time.Minute,
+		TTL:           time.Minute,
+		Version:       1,
+	}
+
+	_, err := Swr(opts)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, fetchCount)
+
+	// Same version is a hit
+	_, err = Swr(opts)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, fetchCount)
+
+	// A new version without a migration is a miss
+	opts.Version = 2
+	_, err = Swr(opts)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, fetchCount)
+}
+
+func TestSWRVersionMigration(t *testing.T) {
+	swrCache := newMemoryCache()
+	defer swrCache.Close()
+
+	var fetchCount int
+	key := []any{"swr_migration_key"}
+
+	_, err := Swr(QueryOptions[string]{
+		Context:     ctx,
+		TieredCache: swrCache,
+		QueryKey:    key,
+		QueryFunction: func() (string, error) {
+			fetchCount++
+			return "old", nil
+		},
+		Fresh: time.Minute,
+		TTL:   time.Minute,
+	})
+	assert.NoError(t, err)
+
+	var migratedFrom int
+	result, err := Swr(QueryOptions[string]{
+		Context:     ctx,
+		TieredCache: swrCache,
+		QueryKey:    key,
+		QueryFunction: func() (string, error) {
+			fetchCount++
+			return "new", nil
+		},
+		Fresh:   time.Minute,
+		TTL:     time.Minute,
+		Version: 1,
+		Migrate: func(version int, data any) (string, error) {
+			migratedFrom = version
+			return data.(string) + "_migrated", nil
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "old_migrated", result)
+	assert.Equal(t, 0, migratedFrom)
+	assert.Equal(t, 1, fetchCount)
+}
+
+func TestCacheVersion(t *testing.T) {
+	bigcacheInstance, _ := bigcache.New(context.Background(), bigcache.DefaultConfig(10*time.Minute))
+	memoryStore := stores.CreateMemoryStore("memory", bigcacheInstance)
+
+	v1 := NewTieredCache(time.Second, []interfaces.CacheStore{memoryStore}, WithCacheVersion("v1"))
+	v2 := NewTieredCache(time.Second, []interfaces.CacheStore{memoryStore}, WithCacheVersion("v2"))
+
+	err := v1.Set(ctx, "versioned_key", CacheItem{Data: "value", Timestamp: time.Now()}, time.Minute)
+	assert.NoError(t, err)
+
+	_, err = v1.Get(ctx, "versioned_key")
+	assert.NoError(t, err)
+
+	_, err = memoryStore.Get(ctx, "v1:versioned_key")
+	assert.NoError(t, err)
+
+	_, err = v2.Get(ctx, "versioned_key")
+	assert.Error(t, err)
+}
+
+func TestSWRLocalStoreReturnsSamePointer(t *testing.T) {
+	type Document struct {
+		Body string
+	}
+
+	bigcacheInstance, _ := bigcache.New(context.Background(), bigcache.DefaultConfig(10*time.Minute))
+	localCache := NewTieredCache(time.Minute, []interfaces.CacheStore{
+		stores.CreateLocalStore("local", stores.LocalStoreConfig{MaxEntries: 100}),
+		stores.CreateMemoryStore("memory", bigcacheInstance),
+	})
+	defer localCache.Close()
+
+	document := &Document{Body: "large body"}
+	opts := QueryOptions[*Document]{
+		Context:       ctx,
+		TieredCache:   localCache,
+		QueryKey:      []any{"document", 1},
+		QueryFunction: func() (*Document, error) { return document, nil },
+		Fresh:         time.Minute,
+		TTL:           time.Minute,
+	}
+
+	_, err := Swr(opts)
+	assert.NoError(t, err)
+
+	result, err := Swr(opts)
+	assert.NoError(t, err)
+	assert.Same(t, document, result)
+}
+
+func TestGetPromotesWithRemainingTTL(t *testing.T) {
+	bigcacheInstance, _ := bigcache.New(context.Background(), bigcache.DefaultConfig(10*time.Minute))
+	localStore := stores.CreateLocalStore("local", stores.LocalStoreConfig{})
+	memoryStore := stores.CreateMemoryStore("memory", bigcacheInstance)
+	promotingCache := NewTieredCache(time.Minute, []interfaces.CacheStore{localStore, memoryStore})
+	defer promotingCache.Close()
+
+	err := memoryStore.Set(ctx, "promoted_key", "value", 200*time.Millisecond)
+	assert.NoError(t, err)
+
+	_, err = localStore.Get(ctx, "promoted_key")
+	assert.Error(t, err)
+
+	value, err := promotingCache.Get(ctx, "promoted_key")
+	assert.NoError(t, err)
+	assert.Equal(t, "value", value)
+
+	value, ttl, err := localStore.(interfaces.TTLStore).GetWithTTL(ctx, "promoted_key")
+	assert.NoError(t, err)
+	assert.Equal(t, "value", value)
+	assert.LessOrEqual(t, ttl, 200*time.Millisecond)
+
+	// The promoted copy expires with the original
+	time.Sleep(250 * time.Millisecond)
+	_, err = localStore.Get(ctx, "promoted_key")
+	assert.Error(t, err)
+}
+
+func TestGetCorruptEntries(t *testing.T) {
+	bigcacheInstance, _ := bigcache.New(context.Background(), bigcache.DefaultConfig(10*time.Minute))
+	localStore := stores.CreateLocalStore("local", stores.LocalStoreConfig{})
+	checksumStore := stores.CreateChecksumStore(localStore, stores.ChecksumStoreConfig{})
+	memoryStore := stores.CreateMemoryStore("memory", bigcacheInstance)
+	corruptCache := NewTieredCache(time.Minute, []interfaces.CacheStore{checksumStore, memoryStore})
+	defer corruptCache.Close()
+
+	err := corruptCache.Set(ctx, "corrupt_key", CacheItem{Data: "value", Timestamp: time.Now()}, time.Minute)
+	assert.NoError(t, err)
+
+	// Flip a byte of the checksummed entry
+	raw, err := localStore.Get(ctx, "corrupt_key")
+	assert.NoError(t, err)
+	corrupted := append([]byte(nil), raw.([]byte)...)
+	corrupted[len(corrupted)-2] ^= 0xff
+	assert.NoError(t, localStore.Set(ctx, "corrupt_key", corrupted, time.Minute))
+
+	// The lookup falls through to the next tier, which promotes a good copy over the corrupt one
+	value, err := corruptCache.Get(ctx, "corrupt_key")
+	assert.NoError(t, err)
+	assert.Equal(t, "value", value.(map[string]any)["data"])
+	assert.Equal(t, uint64(1), corruptCache.CorruptEntries())
+
+	_, err = checksumStore.Get(ctx, "corrupt_key")
+	assert.NoError(t, err)
+}
+
+func TestCorruptionPolicy(t *testing.T) {
+	for _, policy := range []CorruptionPolicy{DeleteCorrupt, KeepCorrupt} {
+		localStore := stores.CreateLocalStore("local", stores.LocalStoreConfig{})
+		checksumStore := stores.CreateChecksumStore(localStore, stores.ChecksumStoreConfig{Checksum: stores.ChecksumXXHash64})
+		policyCache := NewTieredCache(time.Minute, []interfaces.CacheStore{checksumStore}, WithCorruptionPolicy(policy))
+
+		// Written without a checksum, like an entry from another service
+		assert.NoError(t, localStore.Set(ctx, "foreign_key", []byte(`{"data":"value"}`), time.Minute))
+
+		_, err := policyCache.Get(ctx, "foreign_key")
+		assert.Error(t, err)
+		assert.Equal(t, uint64(1), policyCache.CorruptEntries())
+
+		_, err = localStore.Get(ctx, "foreign_key")
+		if policy == DeleteCorrupt {
+			assert.Error(t, err)
+		} else {
+			assert.NoError(t, err)
+		}
+		policyCache.Close()
+	}
+}
+
+// slowStore delays every operation, giving up early when ctx is done.
+type slowStore struct {
+	interfaces.CacheStore
+	name string
+	// mu guards delay, which tests change while lookups started earlier may still be running
+	mu    sync.Mutex
+	delay time.Duration
+}
+
+func (s *slowStore) setDelay(delay time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.delay = delay
+}
+
+func (s *slowStore) Name() string {
+	return s.name
+}
+
+func (s *slowStore) wait(ctx context.Context) error {
+	s.mu.Lock()
+	delay := s.delay
+	s.mu.Unlock()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(delay):
+		return nil
+	}
+}
+
+func (s *slowStore) Get(ctx context.Context, key string) (any, error) {
+	if err := s.wait(ctx); err != nil {
+		return nil, err
+	}
+	return s.CacheStore.Get(ctx, key)
+}
+
+func (s *slowStore) Set(ctx context.Context, key string, value any, ttl time.Duration) error {
+	if err := s.wait(ctx); err != nil {
+		return err
+	}
+	return s.CacheStore.Set(ctx, key, value, ttl)
+}
+
+func TestTierTimeouts(t *testing.T) {
+	slow := &slowStore{CacheStore: stores.CreateLocalStore("local", stores.LocalStoreConfig{}), name: "slow", delay: 100 * time.Millisecond}
+	fast := stores.CreateLocalStore("fast", stores.LocalStoreConfig{})
+	timeoutCache := NewTieredCache(time.Minute, []interfaces.CacheStore{slow, fast},
+		WithTierTimeouts("slow", TierTimeouts{Read: 5 * time.Millisecond, Write: 5 * time.Millisecond}))
+
+	// The slow tier times out, failing the write
+	start := time.Now()
+	err := timeoutCache.Set(ctx, "timeout_key", CacheItem{Data: "value", Timestamp: time.Now()}, time.Minute)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 50*time.Millisecond)
+
+	// Reads move on to the next tier
+	assert.NoError(t, fast.Set(ctx, "timeout_key", "value", time.Minute))
+	start = time.Now()
+	value, err := timeoutCache.Get(ctx, "timeout_key")
+	assert.NoError(t, err)
+	assert.Equal(t, "value", value)
+	assert.Less(t, time.Since(start), 50*time.Millisecond)
+}
+
+func TestLookupBudget(t *testing.T) {
+	first := &slowStore{CacheStore: stores.CreateLocalStore("local", stores.LocalStoreConfig{}), name: "first", delay: 20 * time.Millisecond}
+	second := &slowStore{CacheStore: stores.CreateLocalStore("local", stores.LocalStoreConfig{}), name: "second", delay: 20 * time.Millisecond}
+	budgetCache := NewTieredCache(time.Minute, []interfaces.CacheStore{first, second}, WithLookupBudget(10*time.Millisecond))
+
+	assert.NoError(t, second.CacheStore.Set(ctx, "budget_key", "value", time.Minute))
+
+	// The budget is spent on the first tier, so the second is never asked
+	start := time.Now()
+	_, err := budgetCache.Get(ctx, "budget_key")
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), 18*time.Millisecond)
+
+	opts := QueryOptions[string]{
+		Context:       ctx,
+		TieredCache:   budgetCache,
+		QueryKey:      "budget_query",
+		QueryFunction: func() (string, error) { return "origin", nil },
+		TTL:           time.Minute,
+	}
+	result, err := Swr(opts)
+	assert.NoError(t, err)
+	assert.Equal(t, "origin", result)
+}
+
+func TestSWRRaceOrigin(t *testing.T) {
+	slow := &slowStore{CacheStore: stores.CreateLocalStore("local", stores.LocalStoreConfig{}), name: "slow", delay: 100 * time.Millisecond}
+	raceCache := NewTieredCache(time.Minute, []interfaces.CacheStore{slow})
+
+	// The store key the default key format makes of the QueryKey
+	raceKey := `["race_key"]`
+	err := raceCache.Set(ctx, raceKey, CacheItem{Data: "cached", Timestamp: time.Now()}, time.Minute)
+	assert.NoError(t, err)
+
+	opts := QueryOptions[string]{
+		Context:         ctx,
+		TieredCache:     raceCache,
+		QueryKey:        "race_key",
+		QueryFunction:   func() (string, error) { return "origin", nil },
+		TTL:             time.Minute,
+		RaceOriginAfter: 5 * time.Millisecond,
+	}
+
+	// The origin answers before the slow tier does
+	start := time.Now()
+	result, err := Swr(opts)
+	assert.NoError(t, err)
+	assert.Equal(t, "origin", result)
+	assert.Less(t, time.Since(start), 90*time.Millisecond)
+
+	// The origin's result is cached once the slow tier has written it
+	assert.Eventually(t, func() bool {
+		value, err := slow.CacheStore.Get(ctx, raceKey)
+		return err == nil && value.(map[string]any)["data"] == "origin"
+	}, time.Second, time.Millisecond)
+
+	// When the origin fails the cache still answers
+	opts.QueryFunction = func() (string, error) { return "", errors.New("origin down") }
+	result, err = Swr(opts)
+	assert.NoError(t, err)
+	assert.Equal(t, "origin", result)
+
+	// A cache that answers within RaceOriginAfter never starts the origin
+	slow.setDelay(0)
+	var called atomic.Bool
+	opts.QueryFunction = func() (string, error) { called.Store(true); return "origin", nil }
+	result, err = Swr(opts)
+	assert.NoError(t, err)
+	assert.Equal(t, "origin", result)
+	assert.False(t, called.Load())
+}
+
+// countingRecorder counts measurements by label.
+type countingRecorder struct {
+	mu         sync.Mutex
+	operations map[string]int
+	sizes      map[string]int
+	lookups    map[metrics.SwrOutcome]int
+	refreshes  int
+}
+
+func newCountingRecorder() *countingRecorder {
+	return &countingRecorder{operations: map[string]int{}, sizes: map[string]int{}, lookups: map[metrics.SwrOutcome]int{}}
+}
+
+func (r *countingRecorder) StoreOperation(store string, op stores.Op, result metrics.Result, duration time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.operations[store+" "+string(op)+" "+string(result)]++
+}
+
+func (r *countingRecorder) EntrySize(store string, bytes int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sizes[store] = bytes
+}
+
+func (r *countingRecorder) SwrLookup(outcome metrics.SwrOutcome) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lookups[outcome]++
+}
+
+func (r *countingRecorder) BackgroundRefresh(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.refreshes++
+}
+
+func TestMetrics(t *testing.T) {
+	recorder := newCountingRecorder()
+	l1 := stores.CreateLocalStore("l1", stores.LocalStoreConfig{})
+	l2 := stores.CreateLocalStore("l2", stores.LocalStoreConfig{})
+	metricsCache := NewTieredCache(time.Minute, []interfaces.CacheStore{l1, l2}, WithMetrics(recorder))
+
+	opts := QueryOptions[string]{
+		Context:       ctx,
+		TieredCache:   metricsCache,
+		QueryKey:      "metrics_key",
+		QueryFunction: func() (string, error) { return "value", nil },
+		Fresh:         10 * time.Millisecond,
+		TTL:           time.Minute,
+	}
+
+	// Miss, then fresh, then stale with a background refresh
+	for i := 0; i < 2; i++ {
+		_, err := Swr(opts)
+		assert.NoError(t, err)
+	}
+	time.Sleep(15 * time.Millisecond)
+	_, err := Swr(opts)
+	assert.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		recorder.mu.Lock()
+		defer recorder.mu.Unlock()
+		return recorder.refreshes == 1 && recorder.operations["l2 set ok"] == 2
+	}, time.Second, time.Millisecond)
+
+	assert.NoError(t, metricsCache.Delete(ctx, "metrics_key"))
+
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	assert.Equal(t, map[metrics.SwrOutcome]int{metrics.SwrMiss: 1, metrics.SwrFresh: 1, metrics.SwrStale: 1}, recorder.lookups)
+	assert.Equal(t, 1, recorder.operations["l1 get miss"])
+	assert.Equal(t, 1, recorder.operations["l2 get miss"])
+	assert.Equal(t, 2, recorder.operations["l1 get hit"])
+	assert.Equal(t, 2, recorder.operations["l1 set ok"])
+	assert.Equal(t, 1, recorder.operations["l1 delete ok"])
+	assert.Equal(t, 1, recorder.operations["l2 delete ok"])
+	assert.Greater(t, recorder.sizes["l1"], 0)
+	assert.Equal(t, recorder.sizes["l1"], recorder.sizes["l2"])
+}
@@ -0,0 +1,96 @@
+package tieredcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/reksie/tieredcache/pkg/interfaces"
+	"github.com/reksie/tieredcache/pkg/stores"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/reksie/tieredcache/pkg/tieredcache"
+
+// Span attributes. Keys are hashed, they may hold anything the QueryKey did.
+const (
+	attrStore       = attribute.Key("cache.store")
+	attrHit         = attribute.Key("cache.hit")
+	attrKeyHash     = attribute.Key("cache.key_hash")
+	attrPayloadSize = attribute.Key("cache.payload_size")
+	attrSwrOutcome  = attribute.Key("cache.swr.outcome")
+)
+
+// WithTracerProvider creates the spans of Swr, tier lookups, QueryFunction calls and background refreshes
+// with provider instead of the global one from otel.GetTracerProvider.
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(tc *TieredCache) {
+		tc.tracer = provider.Tracer(tracerName)
+	}
+}
+
+func defaultTracer() trace.Tracer {
+	return otel.GetTracerProvider().Tracer(tracerName)
+}
+
+func keyHash(key string) attribute.KeyValue {
+	return attrKeyHash.String(hashKey(key))
+}
+
+// hashKey identifies a key in traces and logs without revealing it, as keys may hold user data.
+func hashKey(key string) string {
+	return fmt.Sprintf("%016x", xxhash.Sum64String(key))
+}
+
+// payloadSize encodes value to measure it, only call it for spans that are recording.
+func payloadSize(value any) attribute.KeyValue {
+	return attrPayloadSize.Int64(stores.EncodedSizeCost(value))
+}
+
+// startTierSpan starts the span of a lookup in one tier.
+func (tc *TieredCache) startTierSpan(ctx context.Context, store interfaces.CacheStore, key string) (context.Context, trace.Span) {
+	ctx, span := tc.tracer.Start(ctx, "tieredcache.tier.get", trace.WithSpanKind(trace.SpanKindClient))
+	if span.IsRecording() {
+		span.SetAttributes(attrStore.String(store.Name()), keyHash(key))
+	}
+	return ctx, span
+}
+
+// endTierSpan ends the span of a tier lookup. Misses are not errors.
+func endTierSpan(span trace.Span, value any, err error) {
+	if span.IsRecording() {
+		span.SetAttributes(attrHit.Bool(err == nil))
+		if err == nil {
+			span.SetAttributes(payloadSize(value))
+		}
+	}
+	if err != nil && !errors.Is(err, interfaces.ErrNotFound) {
+		endSpan(span, err)
+		return
+	}
+	span.End()
+}
+
+// endSpan ends a span, marking it as failed when err is set.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// query calls the QueryFunction in its own span.
+func query[R any](opts QueryOptions[R]) (R, error) {
+	_, span := opts.TieredCache.tracer.Start(opts.Context, "tieredcache.query")
+	data, err := opts.QueryFunction()
+	if err == nil && span.IsRecording() {
+		span.SetAttributes(payloadSize(data))
+	}
+	endSpan(span, err)
+	return data, err
+}
@@ -0,0 +1,109 @@
+package tieredcache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/reksie/tieredcache/pkg/interfaces"
+	"github.com/reksie/tieredcache/pkg/stores"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func spanAttributes(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	attributes := map[attribute.Key]attribute.Value{}
+	for _, kv := range span.Attributes() {
+		attributes[kv.Key] = kv.Value
+	}
+	return attributes
+}
+
+func spansNamed(spans []sdktrace.ReadOnlySpan, name string) []sdktrace.ReadOnlySpan {
+	var named []sdktrace.ReadOnlySpan
+	for _, span := range spans {
+		if span.Name() == name {
+			named = append(named, span)
+		}
+	}
+	return named
+}
+
+func TestTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	l1 := stores.CreateLocalStore("l1", stores.LocalStoreConfig{})
+	l2 := stores.CreateLocalStore("l2", stores.LocalStoreConfig{})
+	tracedCache := NewTieredCache(time.Minute, []interfaces.CacheStore{l1, l2}, WithTracerProvider(provider))
+
+	opts := QueryOptions[string]{
+		Context:       ctx,
+		TieredCache:   tracedCache,
+		QueryKey:      "traced_key",
+		QueryFunction: func() (string, error) { return "value", nil },
+		Fresh:         10 * time.Millisecond,
+		TTL:           time.Minute,
+	}
+
+	// A miss looks up both tiers, then calls the QueryFunction
+	_, err := Swr(opts)
+	assert.NoError(t, err)
+
+	spans := recorder.Ended()
+	swr := spansNamed(spans, "tieredcache.swr")
+	assert.Len(t, swr, 1)
+	assert.Equal(t, "miss", spanAttributes(swr[0])[attrSwrOutcome].AsString())
+	keyHash := spanAttributes(swr[0])[attrKeyHash].AsString()
+	assert.Len(t, keyHash, 16)
+
+	tiers := spansNamed(spans, "tieredcache.tier.get")
+	assert.Len(t, tiers, 2)
+	for i, name := range []string{"l1", "l2"} {
+		attributes := spanAttributes(tiers[i])
+		assert.Equal(t, name, attributes[attrStore].AsString())
+		assert.False(t, attributes[attrHit].AsBool())
+		assert.Equal(t, keyHash, attributes[attrKeyHash].AsString())
+		assert.Equal(t, codes.Unset, tiers[i].Status().Code)
+		assert.Equal(t, swr[0].SpanContext().SpanID(), tiers[i].Parent().SpanID())
+	}
+
+	queries := spansNamed(spans, "tieredcache.query")
+	assert.Len(t, queries, 1)
+	assert.Equal(t, swr[0].SpanContext().SpanID(), queries[0].Parent().SpanID())
+	assert.Greater(t, spanAttributes(queries[0])[attrPayloadSize].AsInt64(), int64(0))
+
+	// A stale hit is refreshed in a new trace linked to the request's
+	time.Sleep(15 * time.Millisecond)
+	requestCtx, cancel := context.WithCancel(ctx)
+	opts.Context = requestCtx
+	opts.QueryFunction = func() (string, error) { return "", errors.New("origin down") }
+	_, err = Swr(opts)
+	cancel()
+	assert.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		return len(spansNamed(recorder.Ended(), "tieredcache.refresh")) == 1
+	}, time.Second, time.Millisecond)
+
+	spans = recorder.Ended()
+	swr = spansNamed(spans, "tieredcache.swr")
+	assert.Equal(t, "stale", spanAttributes(swr[1])[attrSwrOutcome].AsString())
+	hit := spansNamed(spans, "tieredcache.tier.get")[2]
+	assert.True(t, spanAttributes(hit)[attrHit].AsBool())
+	assert.Greater(t, spanAttributes(hit)[attrPayloadSize].AsInt64(), int64(0))
+
+	refresh := spansNamed(spans, "tieredcache.refresh")[0]
+	assert.NotEqual(t, swr[1].SpanContext().TraceID(), refresh.SpanContext().TraceID())
+	assert.False(t, refresh.Parent().IsValid())
+	assert.Len(t, refresh.Links(), 1)
+	assert.Equal(t, swr[1].SpanContext().SpanID(), refresh.Links()[0].SpanContext.SpanID())
+	assert.Equal(t, codes.Error, refresh.Status().Code)
+
+	failed := spansNamed(spans, "tieredcache.query")[1]
+	assert.Equal(t, refresh.SpanContext().SpanID(), failed.Parent().SpanID())
+	assert.Equal(t, codes.Error, failed.Status().Code)
+}